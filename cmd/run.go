@@ -7,18 +7,21 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,9 +33,12 @@ import (
 
 	"github.com/daeuniverse/dae/cmd/internal"
 	"github.com/daeuniverse/dae/common"
+	"github.com/daeuniverse/dae/common/assets"
 	"github.com/daeuniverse/dae/common/consts"
 	"github.com/daeuniverse/dae/common/netutils"
 	"github.com/daeuniverse/dae/common/subscription"
+	"github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
 	"github.com/daeuniverse/dae/config"
 	"github.com/daeuniverse/dae/control"
 	"github.com/daeuniverse/dae/pkg/config_parser"
@@ -129,7 +135,7 @@ func Run(log *logrus.Logger, conf *config.Config, externGeoDataDirs []string) (e
 	_ = os.Remove(AbortFile)
 
 	// New ControlPlane.
-	c, err := newControlPlane(log, nil, nil, conf, externGeoDataDirs)
+	c, err := newControlPlane(log, nil, nil, conf, externGeoDataDirs, nil)
 	if err != nil {
 		return err
 	}
@@ -148,7 +154,12 @@ func Run(log *logrus.Logger, conf *config.Config, externGeoDataDirs []string) (e
 	go func() {
 		readyChan := make(chan bool, 1)
 		go func() {
-			<-readyChan
+			if ready := <-readyChan; ready {
+				// Inbounds listen in the host network namespace.
+				if err := c.ListenInbounds(); err != nil {
+					log.Errorln("ListenInbounds:", err)
+				}
+			}
 			sdnotify.Ready()
 			if !disablePidFile {
 				_ = os.WriteFile(PidFilePath, []byte(strconv.Itoa(os.Getpid())), 0644)
@@ -186,7 +197,12 @@ loop:
 					}
 					sigs <- nil
 				}()
-				<-readyChan
+				if ready := <-readyChan; ready {
+					if err := c.ListenInbounds(); err != nil {
+						log.Errorln("ListenInbounds:", err)
+						reloadingErr = errors.Join(reloadingErr, err)
+					}
+				}
 				sdnotify.Ready()
 				if reloadingErr == nil {
 					_ = os.WriteFile(SignalProgressFilePath, append([]byte{consts.ReloadDone}, []byte("\nOK")...), 0644)
@@ -260,14 +276,14 @@ loop:
 				dnsCache = c.CloneDnsCache()
 			}
 			log.Warnln("[Reload] Load new control plane")
-			newC, err := newControlPlane(log, obj, dnsCache, newConf, externGeoDataDirs)
+			newC, err := newControlPlane(log, obj, dnsCache, newConf, externGeoDataDirs, c)
 			if err != nil {
 				reloadingErr = err
 				log.WithFields(logrus.Fields{
 					"err": err,
 				}).Errorln("[Reload] Failed to reload; try to roll back configuration")
 				// Load last config back.
-				newC, err = newControlPlane(log, obj, dnsCache, conf, externGeoDataDirs)
+				newC, err = newControlPlane(log, obj, dnsCache, conf, externGeoDataDirs, c)
 				if err != nil {
 					sdnotify.Stopping()
 					obj.Close()
@@ -322,12 +338,15 @@ loop:
 	return nil
 }
 
-func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*control.DnsCache, conf *config.Config, externGeoDataDirs []string) (c *control.ControlPlane, err error) {
+// newControlPlane creates a new control plane. prev is the running control plane on reload, which is nil at the first time.
+func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*control.DnsCache, conf *config.Config, externGeoDataDirs []string, prev *control.ControlPlane) (c *control.ControlPlane, err error) {
 	// Deep copy to prevent modification.
 	conf = deepcopy.Copy(conf).(*config.Config)
 
 	/// Get tag -> nodeList mapping.
 	tagToNodeList := map[string][]string{}
+	// Node link -> annotation mapping from instruction lines of subscriptions.
+	nodeAnnotations := map[string][]*config_parser.Param{}
 	if len(conf.Node) > 0 {
 		for _, node := range conf.Node {
 			tagToNodeList[""] = append(tagToNodeList[""], string(node))
@@ -383,31 +402,134 @@ func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*c
 	if len(conf.Subscription) > 0 {
 		log.Infoln("Fetching subscriptions...")
 	}
-	client := http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (c net.Conn, err error) {
-				conn, err := direct.SymmetricDirect.DialContext(ctx, common.MagicNetwork("tcp", conf.Global.SoMarkFromDae, conf.Global.Mptcp), addr)
-				if err != nil {
-					return nil, err
-				}
-				return &netproxy.FakeNetConn{
-					Conn:  conn,
-					LAddr: nil,
-					RAddr: nil,
-				}, nil
-			},
+	directTransport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (c net.Conn, err error) {
+			conn, err := direct.SymmetricDirect.DialContext(ctx, common.MagicNetwork("tcp", conf.Global.SoMarkFromDae, conf.Global.Mptcp), addr)
+			if err != nil {
+				return nil, err
+			}
+			return &netproxy.FakeNetConn{
+				Conn:  conn,
+				LAddr: nil,
+				RAddr: nil,
+			}, nil
 		},
-		Timeout: 30 * time.Second,
 	}
+	subscriptionOptions := map[string]*config.SubscriptionOption{}
+	for i := range conf.SubscriptionOption {
+		subscriptionOptions[conf.SubscriptionOption[i].Name] = &conf.SubscriptionOption[i]
+	}
+	rewriters := map[string]*subscription.Rewriter{}
+	for _, opt := range subscriptionOptions {
+		if rewriters[opt.Name], err = subscription.NewRewriter(opt); err != nil {
+			return nil, fmt.Errorf(`bad subscription_option "%v": %w`, opt.Name, err)
+		}
+	}
+	// seenNodes records nodes resolved so far for deduplication.
+	seenNodes := map[string]struct{}{}
+	subscription.MarkSeen(tagToNodeList[""], seenNodes)
+	subscriptionInfo := map[string]*subscription.Userinfo{}
+	// resolveSubscriptions fetches subscriptions concurrently, and merges their nodes in order.
+	resolveSubscriptions := func(subs []config.KeyableString, newTransport func(tag string) (transport http.RoundTripper, done func())) {
+		type result struct {
+			tag        string
+			nodes      []string
+			info       *subscription.Userinfo
+			annotation []*config_parser.Param
+			err        error
+		}
+		results := make([]result, len(subs))
+		var wg sync.WaitGroup
+		for i, sub := range subs {
+			tag, _ := common.GetTagFromLinkLikePlaintext(string(sub))
+			timeout, retry := subscription.DefaultTimeout, subscription.DefaultRetry
+			if opt, ok := subscriptionOptions[tag]; ok && tag != "" {
+				timeout, retry = opt.Timeout, int(opt.Retry)
+			}
+			transport, done := newTransport(tag)
+			client := &http.Client{
+				Transport: transport,
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer done()
+				// The timeout covers all attempts and backoff.
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				r := &results[i]
+				r.tag, r.nodes, r.info, r.annotation, r.err = subscription.ResolveSubscription(ctx, log, client, filepath.Dir(cfgFile), string(sub), retry)
+			}()
+		}
+		wg.Wait()
+		for i, r := range results {
+			if r.err != nil {
+				log.Warnf(`failed to resolve subscription "%v": %v`, subs[i], r.err)
+				resolvingfailed = true
+			}
+			nodes := r.nodes
+			if rewriter, ok := rewriters[r.tag]; ok && r.tag != "" {
+				nodes = rewriter.Rewrite(log, nodes, seenNodes)
+			} else {
+				subscription.MarkSeen(nodes, seenNodes)
+			}
+			if len(nodes) > 0 {
+				tagToNodeList[r.tag] = append(tagToNodeList[r.tag], nodes...)
+			}
+			if len(r.annotation) > 0 {
+				if _, err := dialer.NewAnnotation(r.annotation); err != nil {
+					log.Warnf(`bad annotation in instruction line of subscription "%v": %v`, subs[i], err)
+					r.annotation = nil
+				}
+			}
+			if len(r.annotation) > 0 {
+				for _, node := range nodes {
+					nodeAnnotations[node] = r.annotation
+				}
+			}
+			if r.info != nil {
+				name := r.tag
+				if name == "" {
+					_, link := common.GetTagFromLinkLikePlaintext(string(subs[i]))
+					if u, err := url.Parse(link); err == nil {
+						name = u.Host
+					}
+				}
+				r.info.Warn(log, name, conf.Global.SubscriptionTrafficWarning, conf.Global.SubscriptionExpireWarning)
+				subscriptionInfo[name] = r.info
+			}
+		}
+	}
+	// Subscriptions fetched via a group are resolved after others, so that their groups can use nodes from others.
+	var directSubscriptions, viaSubscriptions []config.KeyableString
 	for _, sub := range conf.Subscription {
-		tag, nodes, err := subscription.ResolveSubscription(log, &client, filepath.Dir(cfgFile), string(sub))
-		if err != nil {
-			log.Warnf(`failed to resolve subscription "%v": %v`, sub, err)
-			resolvingfailed = true
+		tag, _ := common.GetTagFromLinkLikePlaintext(string(sub))
+		if opt, ok := subscriptionOptions[tag]; ok && tag != "" && opt.Via != "" {
+			viaSubscriptions = append(viaSubscriptions, sub)
+		} else {
+			directSubscriptions = append(directSubscriptions, sub)
 		}
-		if len(nodes) > 0 {
-			tagToNodeList[tag] = append(tagToNodeList[tag], nodes...)
+	}
+	resolveSubscriptions(directSubscriptions, func(tag string) (http.RoundTripper, func()) {
+		return directTransport, func() {}
+	})
+	resolveSubscriptions(viaSubscriptions, func(tag string) (http.RoundTripper, func()) {
+		via := subscriptionOptions[tag].Via
+		dialContext, closeDialers, err := newGroupDialContext(log, conf, prev, via, tagToNodeList, nodeAnnotations, externGeoDataDirs)
+		if err != nil {
+			log.Warnf(`failed to fetch subscription "%v" via group "%v": %v; fallback to direct`, tag, via, err)
+			return directTransport, func() {}
 		}
+		return &fallbackRoundTripper{
+			log:      log,
+			primary:  &http.Transport{DialContext: dialContext},
+			fallback: directTransport,
+		}, closeDialers
+	})
+	if b, err := json.Marshal(subscriptionInfo); err != nil {
+		log.Warnf("Failed to marshal subscription info: %v", err)
+	} else if err = os.WriteFile(filepath.Join(filepath.Dir(cfgFile), subscription.UserinfoFileName), b, 0600); err != nil {
+		log.Warnf("Failed to write subscription info: %v", err)
 	}
 
 	// Delete all files in persist.d that are not in tagToNodeList
@@ -416,7 +538,7 @@ func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*c
 		return nil, err
 	}
 	for _, file := range files {
-		tag := strings.TrimSuffix(file.Name(), ".sub")
+		tag := strings.TrimSuffix(strings.TrimSuffix(file.Name(), subscription.PersistMetaSuffix), ".sub")
 		if _, ok := tagToNodeList[tag]; !ok {
 			err := os.Remove(filepath.Join(filepath.Dir(cfgFile), "persist.d", file.Name()))
 			if err != nil {
@@ -441,26 +563,121 @@ func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*c
 		return nil, err
 	}
 
+	// Restore check history of nodes from the running control plane, or from the state file at startup.
+	latencyStatePath := filepath.Join(filepath.Dir(cfgFile), outbound.LatencyStateFileName)
+	var latencyState *outbound.LatencyState
+	if prev != nil {
+		latencyState = prev.LatencyState()
+	} else if latencyState, err = outbound.ReadLatencyState(latencyStatePath); err != nil {
+		log.Warnf("Failed to read latency state: %v", err)
+	}
+
 	c, err = control.NewControlPlane(
 		log,
 		bpf,
 		dnsCache,
 		tagToNodeList,
+		nodeAnnotations,
+		latencyState,
+		conf.Wan,
 		conf.Group,
 		&conf.Routing,
 		&conf.Global,
 		&conf.Dns,
+		conf.Inbound,
 		externGeoDataDirs,
 	)
 	if err != nil {
 		return nil, err
 	}
+	// Apply and watch nodes selected at runtime by "dae select".
+	if err := c.WatchSelection(filepath.Join(filepath.Dir(cfgFile), control.SelectionFileName)); err != nil {
+		log.Warnf("Failed to watch selection: %v", err)
+	}
+	if prev != nil {
+		prev.HandOverLatencyState()
+	}
+	c.PersistLatencyState(latencyStatePath)
 	// Call GC to release memory.
 	runtime.GC()
 
 	return c, nil
 }
 
+// newGroupDialContext returns a DialContext that dials through a node of the given group. Alive nodes of the running
+// control plane are preferred; otherwise nodes filtered from tagToNodeList are tried in order.
+func newGroupDialContext(log *logrus.Logger, conf *config.Config, prev *control.ControlPlane, group string, tagToNodeList map[string][]string, nodeAnnotations map[string][]*config_parser.Param, externGeoDataDirs []string) (dialContext func(ctx context.Context, network, addr string) (net.Conn, error), closeDialers func(), err error) {
+	magicNetwork := common.MagicNetwork("tcp", conf.Global.SoMarkFromDae, conf.Global.Mptcp)
+	if prev != nil {
+		d, err := prev.SelectGroupDialer(group)
+		if err == nil {
+			log.Infof(`Fetch subscription via node "%v" of group "%v"`, d.Property().Name, group)
+			return func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := d.DialContext(ctx, magicNetwork, addr)
+				if err != nil {
+					return nil, err
+				}
+				return &netproxy.FakeNetConn{Conn: conn}, nil
+			}, func() {}, nil
+		}
+		log.Debugln(err)
+	}
+
+	var g *config.Group
+	for i := range conf.Group {
+		if conf.Group[i].Name == group {
+			g = &conf.Group[i]
+			break
+		}
+	}
+	if g == nil {
+		return nil, nil, fmt.Errorf("group does not exist")
+	}
+	dialerSet := outbound.NewDialerSetFromLinks(dialer.NewGlobalOption(&conf.Global, log), tagToNodeList, nodeAnnotations, conf.Wan, assets.NewLocationFinder(externGeoDataDirs), nil)
+	dialers, _, err := dialerSet.FilterAndAnnotate(g.Filter, g.FilterAnnotation)
+	if err != nil {
+		_ = dialerSet.Close()
+		return nil, nil, err
+	}
+	if len(dialers) == 0 {
+		_ = dialerSet.Close()
+		return nil, nil, fmt.Errorf("no node in group")
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var errs []error
+		for _, d := range dialers {
+			dialCtx, cancel := context.WithTimeout(ctx, consts.DefaultDialTimeout)
+			conn, err := d.DialContext(dialCtx, magicNetwork, addr)
+			cancel()
+			if err == nil {
+				log.Infof(`Fetch subscription via node "%v" of group "%v"`, d.Property().Name, group)
+				return &netproxy.FakeNetConn{Conn: conn}, nil
+			}
+			errs = append(errs, fmt.Errorf("%v: %w", d.Property().Name, err))
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}, func() { _ = dialerSet.Close() }, nil
+}
+
+// fallbackRoundTripper sends requests by fallback if primary fails.
+type fallbackRoundTripper struct {
+	log      *logrus.Logger
+	primary  http.RoundTripper
+	fallback http.RoundTripper
+}
+
+func (t *fallbackRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.primary.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	t.log.Warnf("failed to request %v via group: %v; fallback to direct", req.URL.Host, err)
+	return t.fallback.RoundTrip(req)
+}
+
 func preprocessWanInterfaceAuto(params *config.Config) error {
 	// preprocess "auto".
 	ifs := make([]string, 0, len(params.Global.WanInterface)+2)
@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/daeuniverse/dae/cmd/internal"
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/control"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selectCmd = &cobra.Command{
+		Use:   "select [group] [node]",
+		Short: "To select a node for a group with policy select. The running dae applies it without reloading. Omit node to clear the selection, and omit both to show selections.",
+		Args:  cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfgFile == "" {
+				fmt.Println("Argument \"--config\" or \"-c\" is required but not provided.")
+				os.Exit(1)
+			}
+			path := filepath.Join(filepath.Dir(cfgFile), control.SelectionFileName)
+			selection, err := control.ReadSelection(path)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if len(args) == 0 {
+				groups := make([]string, 0, len(selection))
+				for group := range selection {
+					groups = append(groups, group)
+				}
+				sort.Strings(groups)
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "GROUP\tNODE")
+				for _, group := range groups {
+					fmt.Fprintf(w, "%v\t%v\n", group, selection[group])
+				}
+				w.Flush()
+				return
+			}
+
+			internal.AutoSu()
+			conf, _, err := readConfig(cfgFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if err = checkSelectGroup(conf, args[0]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if len(args) == 1 {
+				delete(selection, args[0])
+			} else {
+				selection[args[0]] = args[1]
+			}
+			if err = control.WriteSelection(path, selection); err != nil {
+				fmt.Println("Failed to write selection file:", err)
+				os.Exit(1)
+			}
+			fmt.Println("OK")
+		},
+	}
+)
+
+func checkSelectGroup(conf *config.Config, name string) error {
+	for _, g := range conf.Group {
+		if g.Name != name {
+			continue
+		}
+		if fs := config.FunctionListOrStringToFunctionList(g.Policy); len(fs) != 1 || fs[0].Name != string(consts.DialerSelectionPolicy_Select) {
+			return fmt.Errorf("policy of group %v is not %v", name, consts.DialerSelectionPolicy_Select)
+		}
+		return nil
+	}
+	return fmt.Errorf("group %v does not exist", name)
+}
+
+func init() {
+	rootCmd.AddCommand(selectCmd)
+
+	selectCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file")
+}
@@ -0,0 +1,73 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/daeuniverse/dae/common/subscription"
+	"github.com/spf13/cobra"
+)
+
+var (
+	subscriptionCmd = &cobra.Command{
+		Use:   "subscription",
+		Short: "Show traffic and expiry information of subscriptions fetched by the running dae.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfgFile == "" {
+				fmt.Println("Argument \"--config\" or \"-c\" is required but not provided.")
+				os.Exit(1)
+			}
+			b, err := os.ReadFile(filepath.Join(filepath.Dir(cfgFile), subscription.UserinfoFileName))
+			if err != nil {
+				fmt.Println("Failed to read subscription info file:", err)
+				os.Exit(1)
+			}
+			var infos map[string]*subscription.Userinfo
+			if err = json.Unmarshal(b, &infos); err != nil {
+				fmt.Println("Bad subscription info file:", err)
+				os.Exit(1)
+			}
+			if len(infos) == 0 {
+				fmt.Println("No subscription provides traffic or expiry information.")
+				return
+			}
+			tags := make([]string, 0, len(infos))
+			for tag := range infos {
+				tags = append(tags, tag)
+			}
+			sort.Strings(tags)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SUBSCRIPTION\tUSED\tTOTAL\tREMAINING\tEXPIRE")
+			for _, tag := range tags {
+				info := infos[tag]
+				total, remaining, expire := "-", "-", "-"
+				if r, ok := info.Remaining(); ok {
+					total = subscription.FormatBytes(info.Total)
+					remaining = subscription.FormatBytes(max(r, 0))
+				}
+				if t, ok := info.ExpireAt(); ok {
+					expire = t.Format(time.DateTime)
+				}
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", tag, subscription.FormatBytes(info.Used()), total, remaining, expire)
+			}
+			w.Flush()
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(subscriptionCmd)
+
+	subscriptionCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file")
+}
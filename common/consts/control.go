@@ -24,3 +24,35 @@ func ParseDialMode(mode string) (DialMode, error) {
 		return "", fmt.Errorf("unsupported dial mode: %v", mode)
 	}
 }
+
+// UdpNatMode decides whether a UDP endpoint is shared by all destinations of a client socket. It is carried by routing
+// rules to the kernel, where zero means to follow the global config.
+type UdpNatMode uint8
+
+const (
+	UdpNatMode_Default UdpNatMode = iota
+	UdpNatMode_FullCone
+	UdpNatMode_PerDestination
+)
+
+func ParseUdpNatMode(mode string) (UdpNatMode, error) {
+	switch mode {
+	case "full_cone":
+		return UdpNatMode_FullCone, nil
+	case "per_destination":
+		return UdpNatMode_PerDestination, nil
+	default:
+		return UdpNatMode_Default, fmt.Errorf("unsupported udp nat mode: %v", mode)
+	}
+}
+
+func (m UdpNatMode) String() string {
+	switch m {
+	case UdpNatMode_FullCone:
+		return "full_cone"
+	case UdpNatMode_PerDestination:
+		return "per_destination"
+	default:
+		return "default"
+	}
+}
@@ -7,6 +7,7 @@ package consts
 
 import (
 	"net/netip"
+	"strings"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -18,10 +19,45 @@ const (
 	DialerSelectionPolicy_Random                    DialerSelectionPolicy = "random"
 	DialerSelectionPolicy_Fixed                     DialerSelectionPolicy = "fixed"
 	DialerSelectionPolicy_MinAverage10Latencies     DialerSelectionPolicy = "min_avg10"
+	DialerSelectionPolicy_MinAverage10WithLoss      DialerSelectionPolicy = "min_avg10_with_loss"
 	DialerSelectionPolicy_MinMovingAverageLatencies DialerSelectionPolicy = "min_moving_avg"
 	DialerSelectionPolicy_MinLastLatency            DialerSelectionPolicy = "min"
+	DialerSelectionPolicy_Sticky                    DialerSelectionPolicy = "sticky"
+	DialerSelectionPolicy_Balance                   DialerSelectionPolicy = "balance"
+	DialerSelectionPolicy_Failover                  DialerSelectionPolicy = "failover"
+	DialerSelectionPolicy_Select                    DialerSelectionPolicy = "select"
 )
 
+type StickyKey string
+
+const (
+	StickyKey_SrcIp          StickyKey = "src_ip"
+	StickyKey_Mac            StickyKey = "mac"
+	StickyKey_SrcIpDstDomain StickyKey = "src_ip+dst_domain"
+
+	DefaultStickyTtl = 30 * time.Minute
+)
+
+const DefaultMaxLoss = 0.2
+
+// protocolAlias maps aliases of protocols to the protocol names of dialer properties.
+var protocolAlias = map[string]string{
+	"ss":    "shadowsocks",
+	"ssr":   "shadowsocksr",
+	"hy2":   "hysteria2",
+	"socks": "socks5",
+}
+
+// CanonicalProtocol returns the protocol name of dialer properties for the protocol or its alias, e.g. "shadowsocks"
+// for "ss".
+func CanonicalProtocol(protocol string) string {
+	protocol = strings.ToLower(protocol)
+	if alias, ok := protocolAlias[protocol]; ok {
+		return alias
+	}
+	return protocol
+}
+
 const (
 	UdpCheckLookupHost = "connectivitycheck.gstatic.com."
 	DefaultDialTimeout = 8 * time.Second
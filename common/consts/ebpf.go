@@ -68,13 +68,18 @@ const (
 
 	OutboundUserDefinedMin
 
+	// Drop and reject take reserved indexes, so that indexes of user defined groups shared with the kernel program
+	// keep unchanged.
+	OutboundRejectIcmp          OutboundIndex = 0xF9
+	OutboundRejectTcpReset      OutboundIndex = 0xFA
+	OutboundDrop                OutboundIndex = 0xFB
 	OutboundMustRules           OutboundIndex = 0xFC
 	OutboundControlPlaneRouting OutboundIndex = 0xFD
 	OutboundLogicalOr           OutboundIndex = 0xFE
 	OutboundLogicalAnd          OutboundIndex = 0xFF
 	OutboundLogicalMask         OutboundIndex = 0xFE
 
-	OutboundUserDefinedMax = OutboundMustRules - 1
+	OutboundUserDefinedMax = OutboundRejectIcmp - 1
 )
 
 func (i OutboundIndex) String() string {
@@ -85,6 +90,12 @@ func (i OutboundIndex) String() string {
 		return "direct"
 	case OutboundBlock:
 		return "block"
+	case OutboundDrop:
+		return "drop"
+	case OutboundRejectTcpReset:
+		return OutboundReject + "(" + RejectWith_TcpReset + ")"
+	case OutboundRejectIcmp:
+		return OutboundReject + "(" + RejectWith_IcmpUnreached + ")"
 	case OutboundControlPlaneRouting:
 		return "<Control Plane Routing>"
 	case OutboundLogicalOr:
@@ -96,6 +107,11 @@ func (i OutboundIndex) String() string {
 	}
 }
 
+// IsRejection returns true if traffic to the outbound should be dropped or rejected instead of being dialed.
+func (i OutboundIndex) IsRejection() bool {
+	return i == OutboundDrop || i == OutboundRejectTcpReset || i == OutboundRejectIcmp
+}
+
 func (i OutboundIndex) IsReserved() bool {
 	return !strings.HasPrefix(i.String(), "<index: ")
 }
@@ -28,5 +28,16 @@ const (
 	Function_QType    = "qtype"
 	Function_Upstream = "upstream"
 
-	OutboundParam_Mark = "mark"
+	OutboundParam_Mark       = "mark"
+	OutboundParam_UdpNatMode = "udp_nat_mode"
+
+	// OutboundReject is used like "reject(tcp_reset)" or "reject(icmp)" in routing.
+	OutboundReject           = "reject"
+	RejectWith_TcpReset      = "tcp_reset"
+	RejectWith_IcmpUnreached = "icmp"
+
+	// OutboundRedirect is used like "redirect('127.0.0.1:8080')" or "redirect('127.0.0.1:8080', proxy_protocol: v2)".
+	OutboundRedirect            = "redirect"
+	OutboundParam_ProxyProtocol = "proxy_protocol"
+	ProxyProtocol_V2            = "v2"
 )
@@ -0,0 +1,102 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetry   = 2
+
+	// PersistMetaSuffix is the suffix of file that stores caching headers of a persisted subscription.
+	PersistMetaSuffix = ".meta"
+
+	maxBackoff = 8 * time.Second
+)
+
+// persistMeta is stored beside the persisted subscription to make conditional requests.
+type persistMeta struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Userinfo     *Userinfo `json:"userinfo,omitempty"`
+}
+
+func readPersistMeta(path string) *persistMeta {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var meta persistMeta
+	if err = json.Unmarshal(b, &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+func writePersistMeta(path string, meta *persistMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// doWithRetry sends the request and retries with exponential backoff on network errors and 5xx responses.
+func doWithRetry(log *logrus.Logger, client *http.Client, req *http.Request, retry int) (resp *http.Response, err error) {
+	backoff := time.Second
+	for i := 0; ; i++ {
+		resp, err = client.Do(req)
+		if err == nil {
+			if resp.StatusCode < 500 {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("bad status: %v", resp.Status)
+		}
+		if i >= retry {
+			return nil, err
+		}
+		log.Debugf("failed to fetch %v (attempt %v/%v): %v; retry in %v", req.URL.Host, i+1, retry+1, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, fmt.Errorf("%w; last error: %v", req.Context().Err(), err)
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// readBody reads the response body and decodes it according to Content-Encoding.
+func readBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case "br":
+		r = brotli.NewReader(resp.Body)
+	case "", "identity":
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: %v", resp.Header.Get("Content-Encoding"))
+	}
+	return io.ReadAll(r)
+}
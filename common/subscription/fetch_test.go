@@ -0,0 +1,87 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestResolveSubscription_Conditional(t *testing.T) {
+	body := base64.StdEncoding.EncodeToString([]byte("trojan://password@1.2.3.4:443#node"))
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Fail the first request to test retry.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set(UserinfoHeader, "upload=1; download=2; total=10")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			defer gw.Close()
+			_, _ = gw.Write([]byte(body))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sub := "my_sub:" + strings.Replace(server.URL, "http://", "http-file://", 1)
+	for i := 0; i < 2; i++ {
+		tag, nodes, info, _, err := ResolveSubscription(context.Background(), logrus.StandardLogger(), server.Client(), dir, sub, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != "my_sub" || len(nodes) != 1 || nodes[0] != "trojan://password@1.2.3.4:443#node" {
+			t.Fatalf("unexpected result: %v %v", tag, nodes)
+		}
+		if info == nil || info.Total != 10 {
+			t.Fatalf("unexpected userinfo: %+v", info)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Fatalf("expected a 304 response, got %v", notModified.Load())
+	}
+}
+
+func TestDoWithRetry_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = doWithRetry(logrus.StandardLogger(), server.Client(), req, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("backoff does not stop when the request is canceled: %v", elapsed)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/daeuniverse/dae/pkg/config_parser"
+)
+
+const (
+	InstructionKey_Format = "format"
+	InstructionKey_Prefix = "prefix"
+
+	Format_Base64  = "base64"
+	Format_SIP008  = "sip008"
+	Format_SingBox = "singbox"
+	Format_Plain   = "plain"
+)
+
+// Instruction is declared by the first line of a subscription file beginning with "@", like:
+//
+//	@format: singbox; prefix: 'HK '; add_latency: 50ms
+//
+// Values can be quoted if they contain ";". Keys other than format and prefix are node annotations applied to all
+// nodes of the subscription, which are checked by the caller.
+type Instruction struct {
+	// Format is the format of the content. Empty means to guess it.
+	Format string
+	// Prefix is prepended to names of nodes.
+	Prefix     string
+	Annotation []*config_parser.Param
+}
+
+// SplitInstruction splits the instruction line from the content. inst is nil if there is no instruction line.
+func SplitInstruction(b []byte) (inst *Instruction, content []byte, err error) {
+	if !bytes.HasPrefix(b, []byte("@")) {
+		return nil, b, nil
+	}
+	line, content, _ := bytes.Cut(b, []byte("\n"))
+	inst, err = ParseInstruction(strings.TrimSpace(string(line[1:])))
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, content, nil
+}
+
+func ParseInstruction(line string) (inst *Instruction, err error) {
+	inst = &Instruction{}
+	fields, err := splitInstructionFields(line)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		key, val, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad instruction field %q: expect key: value", field)
+		}
+		key, val = strings.TrimSpace(key), unquote(strings.TrimSpace(val))
+		switch key {
+		case InstructionKey_Format:
+			switch val {
+			case Format_Base64, Format_SIP008, Format_SingBox, Format_Plain:
+				inst.Format = val
+			default:
+				return nil, fmt.Errorf("unknown format %q; available formats: base64, sip008, singbox, plain", val)
+			}
+		case InstructionKey_Prefix:
+			inst.Prefix = val
+		default:
+			inst.Annotation = append(inst.Annotation, &config_parser.Param{Key: key, Val: val})
+		}
+	}
+	return inst, nil
+}
+
+// splitInstructionFields splits the line by ";" outside quotes.
+func splitInstructionFields(line string) (fields []string, err error) {
+	var (
+		quote rune
+		start int
+	)
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			fields = append(fields, line[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote in instruction: %v", line)
+	}
+	fields = append(fields, line[start:])
+	// Omit empty fields.
+	n := 0
+	for _, field := range fields {
+		if strings.TrimSpace(field) != "" {
+			fields[n] = field
+			n++
+		}
+	}
+	return fields[:n], nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// ResolveSubscriptionAsPlain resolves content with one link per line. Empty lines and lines beginning with "#" are
+// ignored.
+func ResolveSubscriptionAsPlain(b []byte) (nodes []string) {
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nodes = append(nodes, line)
+	}
+	return nodes
+}
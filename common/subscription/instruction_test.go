@@ -0,0 +1,42 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"testing"
+)
+
+func TestSplitInstruction(t *testing.T) {
+	inst, content, err := SplitInstruction([]byte("@format: plain; prefix: 'HK; '; add_latency: 50ms\ntrojan://pass@1.2.3.4:443#a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Format != Format_Plain || inst.Prefix != "HK; " {
+		t.Fatalf("unexpected instruction: %+v", inst)
+	}
+	if len(inst.Annotation) != 1 || inst.Annotation[0].Key != "add_latency" || inst.Annotation[0].Val != "50ms" {
+		t.Fatalf("unexpected annotation: %v", inst.Annotation)
+	}
+	if nodes := ResolveSubscriptionAsPlain(content); len(nodes) != 1 || nodes[0] != "trojan://pass@1.2.3.4:443#a" {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+
+	if inst, _, err = SplitInstruction([]byte("trojan://pass@1.2.3.4:443#a")); err != nil || inst != nil {
+		t.Fatalf("expected no instruction, got %v, %v", inst, err)
+	}
+}
+
+func TestParseInstruction_Bad(t *testing.T) {
+	for _, line := range []string{
+		"format: xml",
+		"prefix",
+		"prefix: 'HK",
+	} {
+		if _, err := ParseInstruction(line); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/daeuniverse/outbound/dialer/shadowsocksr"
+	"github.com/daeuniverse/outbound/dialer/v2ray"
+	"github.com/dlclark/regexp2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RewriteInput_Name     = "name"
+	RewriteInput_Protocol = "protocol"
+	RewriteInput_Address  = "address"
+	RewriteInput_Replace  = "replace"
+
+	RewriteKey_Regex   = "regex"
+	RewriteKey_Keyword = "keyword"
+	RewriteKey_With    = "with"
+)
+
+// Node is the parsed view of a node link which is used to rewrite it.
+type Node struct {
+	Link     string
+	Name     string
+	Protocol string
+	Host     string
+	Port     string
+	// Credential is the user info of the link, such as password or uuid.
+	Credential string
+
+	v2ray *v2ray.V2Ray
+	ssr   *shadowsocksr.ShadowsocksR
+}
+
+func ParseNode(link string) (*Node, error) {
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok {
+		return nil, fmt.Errorf("bad link: %v", link)
+	}
+	n := &Node{Link: link, Protocol: consts.CanonicalProtocol(scheme)}
+	switch n.Protocol {
+	case "vmess":
+		v, err := v2ray.ParseVmessURL(link)
+		if err != nil {
+			return nil, err
+		}
+		n.v2ray = v
+		n.Name, n.Host, n.Port, n.Credential = v.Ps, v.Add, v.Port, v.ID
+	case "shadowsocksr":
+		s, err := shadowsocksr.ParseSSRURL(link)
+		if err != nil {
+			return nil, err
+		}
+		n.ssr = s
+		n.Name, n.Host, n.Port = s.Name, s.Server, fmt.Sprint(s.Port)
+		n.Credential = strings.Join([]string{s.Proto, s.Cipher, s.Obfs, s.Password}, ":")
+	default:
+		u, err := url.Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		n.Name, n.Host, n.Port = u.Fragment, u.Hostname(), u.Port()
+		if u.User != nil {
+			n.Credential = u.User.String()
+		}
+	}
+	return n, nil
+}
+
+// Key identifies the server and credential of the node.
+func (n *Node) Key() string {
+	return n.Protocol + "://" + n.Credential + "@" + net.JoinHostPort(n.Host, n.Port)
+}
+
+// SetName sets the name of the node and updates its link.
+func (n *Node) SetName(name string) {
+	n.Name = name
+	switch {
+	case n.v2ray != nil:
+		n.v2ray.Ps = name
+		n.Link = n.v2ray.ExportToURL()
+	case n.ssr != nil:
+		n.ssr.Name = name
+		n.Link = n.ssr.ExportToURL()
+	default:
+		link, _, _ := strings.Cut(n.Link, "#")
+		n.Link = link + "#" + url.PathEscape(name)
+	}
+}
+
+type renameRule struct {
+	regex   *regexp2.Regexp
+	keyword string
+	with    string
+}
+
+// dropFilter is a checked drop filter with compiled regexps.
+type dropFilter struct {
+	input  string
+	not    bool
+	params []*dropParam
+}
+
+type dropParam struct {
+	key   string
+	val   string
+	regex *regexp2.Regexp
+}
+
+// Rewriter rewrites nodes of a subscription according to the subscription option.
+type Rewriter struct {
+	tag       string
+	drop      [][]*dropFilter
+	rename    []*renameRule
+	dedupe    bool
+	tagPrefix bool
+}
+
+func NewRewriter(option *config.SubscriptionOption) (*Rewriter, error) {
+	r := &Rewriter{
+		tag:       option.Name,
+		dedupe:    option.Dedupe,
+		tagPrefix: option.TagPrefix,
+	}
+	for _, functions := range option.Drop {
+		filters := make([]*dropFilter, 0, len(functions))
+		for _, function := range functions {
+			filter, err := newDropFilter(function)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		r.drop = append(r.drop, filters)
+	}
+	for _, functions := range option.Rename {
+		if len(functions) == 0 {
+			return nil, fmt.Errorf("empty rename rule: use replace(regex: ..., with: ...) or replace(keyword: ..., with: ...)")
+		}
+		if len(functions) != 1 || functions[0].Name != RewriteInput_Replace || functions[0].Not {
+			return nil, fmt.Errorf(`unsupported rename rule "%v": use replace(regex: ..., with: ...) or replace(keyword: ..., with: ...)`, functions[0].String(false, true, true))
+		}
+		var rule renameRule
+		for _, param := range functions[0].Params {
+			switch param.Key {
+			case RewriteKey_Regex:
+				regex, err := regexp2.Compile(param.Val, 0)
+				if err != nil {
+					return nil, fmt.Errorf("bad regexp in rename rule %v: %w", functions[0].String(false, true, true), err)
+				}
+				rule.regex = regex
+			case RewriteKey_Keyword:
+				rule.keyword = param.Val
+			case RewriteKey_With:
+				rule.with = param.Val
+			default:
+				return nil, fmt.Errorf(`unsupported key "%v" in "rename: %v()"`, param.Key, functions[0].Name)
+			}
+		}
+		if (rule.regex == nil) == (rule.keyword == "") {
+			return nil, fmt.Errorf("rename rule %v requires either regex or keyword", functions[0].String(false, true, true))
+		}
+		r.rename = append(r.rename, &rule)
+	}
+	return r, nil
+}
+
+// Rewrite drops, deduplicates, renames and prefixes nodes in order. seen records keys of nodes from all subscriptions
+// for deduplication, and nodes of this subscription will also be added to it.
+func (r *Rewriter) Rewrite(log *logrus.Logger, links []string, seen map[string]struct{}) (rewritten []string) {
+nextLink:
+	for _, link := range links {
+		n, err := ParseNode(link)
+		if err != nil {
+			log.Debugf("failed to parse node to rewrite, keep it as is: %v", err)
+			rewritten = append(rewritten, link)
+			continue
+		}
+		for _, filters := range r.drop {
+			if matchNode(n, filters) {
+				log.Debugf(`Drop node "%v" from subscription "%v"`, n.Name, r.tag)
+				continue nextLink
+			}
+		}
+		key := n.Key()
+		if _, exist := seen[key]; exist && r.dedupe {
+			log.Debugf(`Drop duplicated node "%v" from subscription "%v"`, n.Name, r.tag)
+			continue
+		}
+		seen[key] = struct{}{}
+		name := n.Name
+		for _, rule := range r.rename {
+			if rule.regex != nil {
+				name, _ = rule.regex.Replace(name, rule.with, -1, -1)
+			} else {
+				name = strings.ReplaceAll(name, rule.keyword, rule.with)
+			}
+		}
+		if r.tagPrefix && r.tag != "" {
+			name = "[" + r.tag + "] " + name
+		}
+		if name != n.Name {
+			n.SetName(name)
+		}
+		rewritten = append(rewritten, n.Link)
+	}
+	return rewritten
+}
+
+// MarkSeen records keys of given nodes for deduplication.
+func MarkSeen(links []string, seen map[string]struct{}) {
+	for _, link := range links {
+		if n, err := ParseNode(link); err == nil {
+			seen[n.Key()] = struct{}{}
+		}
+	}
+}
+
+// newDropFilter checks the drop filter and compiles its regexps.
+func newDropFilter(function *config_parser.Function) (*dropFilter, error) {
+	switch function.Name {
+	case RewriteInput_Name, RewriteInput_Address, RewriteInput_Protocol:
+	default:
+		return nil, fmt.Errorf(`unsupported drop input type: "%v"`, function.Name)
+	}
+	filter := &dropFilter{
+		input: function.Name,
+		not:   function.Not,
+	}
+	for _, param := range function.Params {
+		p := &dropParam{key: param.Key, val: param.Val}
+		switch param.Key {
+		case RewriteKey_Regex:
+			regex, err := regexp2.Compile(param.Val, 0)
+			if err != nil {
+				return nil, fmt.Errorf("bad regexp in drop %v: %w", function.String(false, true, true), err)
+			}
+			p.regex = regex
+			fallthrough
+		case RewriteKey_Keyword:
+			if function.Name == RewriteInput_Protocol {
+				return nil, fmt.Errorf(`unsupported key "%v" in "drop: %v()"`, param.Key, function.Name)
+			}
+		case "":
+			if function.Name == RewriteInput_Protocol {
+				p.val = consts.CanonicalProtocol(p.val)
+			}
+		default:
+			return nil, fmt.Errorf(`unsupported key "%v" in "drop: %v()"`, param.Key, function.Name)
+		}
+		filter.params = append(filter.params, p)
+	}
+	return filter, nil
+}
+
+// matchNode reports whether the node hits all filters.
+func matchNode(n *Node, filters []*dropFilter) bool {
+	// And
+	for _, filter := range filters {
+		var subFilterHit bool
+		var input string
+		switch filter.input {
+		case RewriteInput_Name:
+			input = n.Name
+		case RewriteInput_Address:
+			input = n.Host
+		case RewriteInput_Protocol:
+			input = n.Protocol
+		}
+		// Or
+	loop:
+		for _, param := range filter.params {
+			switch param.key {
+			case RewriteKey_Regex:
+				if matched, _ := param.regex.MatchString(input); matched {
+					subFilterHit = true
+					break loop
+				}
+			case RewriteKey_Keyword:
+				if strings.Contains(input, param.val) {
+					subFilterHit = true
+					break loop
+				}
+			default:
+				if input == param.val {
+					subFilterHit = true
+					break loop
+				}
+			}
+		}
+		if subFilterHit == filter.not {
+			return false
+		}
+	}
+	return true
+}
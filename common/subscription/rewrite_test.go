@@ -0,0 +1,71 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRewriter(t *testing.T) {
+	vmess := "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"HK 01","add":"hk.example.com","port":"443","id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":"0","net":"tcp","type":"none","tls":"tls"}`))
+	links := []string{
+		"trojan://password@1.2.3.4:443#Expire:%202026-01-01",
+		"trojan://password@1.2.3.4:443#HK%2002",
+		"trojan://another@1.2.3.4:443#HK%2003",
+		"ss://YWVzLTEyOC1nY206dGVzdA@5.6.7.8:8388#US%2001",
+		vmess,
+	}
+	option := &config.SubscriptionOption{
+		Name: "my_sub",
+		Drop: [][]*config_parser.Function{
+			{{Name: "name", Params: []*config_parser.Param{{Key: "keyword", Val: "Expire"}}}},
+			{{Name: "protocol", Params: []*config_parser.Param{{Val: "shadowsocks"}}}},
+		},
+		Rename: [][]*config_parser.Function{
+			{{Name: "replace", Params: []*config_parser.Param{{Key: "regex", Val: `^HK (\d+)$`}, {Key: "with", Val: "Hong Kong $1"}}}},
+		},
+		Dedupe:    true,
+		TagPrefix: true,
+	}
+	r, err := NewRewriter(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]struct{}{}
+	MarkSeen([]string{"trojan://password@1.2.3.4:443#Mirror"}, seen)
+	rewritten := r.Rewrite(logrus.StandardLogger(), links, seen)
+	if len(rewritten) != 2 {
+		t.Fatalf("expected 2 nodes, got %v: %v", len(rewritten), rewritten)
+	}
+	if rewritten[0] != "trojan://another@1.2.3.4:443#%5Bmy_sub%5D%20Hong%20Kong%2003" {
+		t.Fatalf("unexpected link: %v", rewritten[0])
+	}
+	n, err := ParseNode(rewritten[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "[my_sub] Hong Kong 01" || n.Host != "hk.example.com" || n.Port != "443" {
+		t.Fatalf("unexpected vmess node: %+v", n)
+	}
+}
+
+func TestNewRewriter_BadRule(t *testing.T) {
+	for _, option := range []*config.SubscriptionOption{
+		{Drop: [][]*config_parser.Function{{{Name: "subtag", Params: []*config_parser.Param{{Val: "a"}}}}}},
+		{Drop: [][]*config_parser.Function{{{Name: "protocol", Params: []*config_parser.Param{{Key: "regex", Val: "^v"}}}}}},
+		{Rename: [][]*config_parser.Function{{{Name: "replace", Params: []*config_parser.Param{{Key: "with", Val: "x"}}}}}},
+		{Rename: [][]*config_parser.Function{{}}},
+	} {
+		if _, err := NewRewriter(option); err == nil {
+			t.Fatalf("expected error for %+v", option)
+		}
+	}
+}
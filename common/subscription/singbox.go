@@ -0,0 +1,442 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/daeuniverse/dae/common"
+	"github.com/sirupsen/logrus"
+)
+
+type singBox struct {
+	Outbounds []singBoxOutbound `json:"outbounds"`
+}
+
+type singBoxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+
+	// Credentials.
+	Uuid     string `json:"uuid"`
+	Password string `json:"password"`
+	Username string `json:"username"`
+	Method   string `json:"method"`
+	Security string `json:"security"`
+	AlterId  int    `json:"alter_id"`
+	Flow     string `json:"flow"`
+	Version  string `json:"version"`
+
+	// Shadowsocks.
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+
+	// Hysteria2 and TUIC.
+	UpMbps            int                 `json:"up_mbps"`
+	DownMbps          int                 `json:"down_mbps"`
+	Obfs              *singBoxObfs        `json:"obfs"`
+	CongestionControl string              `json:"congestion_control"`
+	UdpRelayMode      string              `json:"udp_relay_mode"`
+	Tls               *singBoxTls         `json:"tls"`
+	Transport         *singBoxTransport   `json:"transport"`
+	Detour            string              `json:"detour"`
+	Multiplex         *singBoxMultiplexer `json:"multiplex"`
+}
+
+type singBoxObfs struct {
+	Type     string `json:"type"`
+	Password string `json:"password"`
+}
+
+type singBoxTls struct {
+	Enabled    bool     `json:"enabled"`
+	ServerName string   `json:"server_name"`
+	Insecure   bool     `json:"insecure"`
+	Alpn       []string `json:"alpn"`
+	Utls       *struct {
+		Enabled     bool   `json:"enabled"`
+		Fingerprint string `json:"fingerprint"`
+	} `json:"utls"`
+	Reality *struct {
+		Enabled   bool   `json:"enabled"`
+		PublicKey string `json:"public_key"`
+		ShortId   string `json:"short_id"`
+	} `json:"reality"`
+}
+
+type singBoxTransport struct {
+	Type        string            `json:"type"`
+	Host        json.RawMessage   `json:"host"`
+	Path        string            `json:"path"`
+	Headers     map[string]string `json:"headers"`
+	ServiceName string            `json:"service_name"`
+}
+
+// singBoxVmess is the v2rayN style JSON of a vmess link.
+type singBoxVmess struct {
+	V             string `json:"v"`
+	Ps            string `json:"ps"`
+	Add           string `json:"add"`
+	Port          string `json:"port"`
+	ID            string `json:"id"`
+	Aid           string `json:"aid"`
+	Scy           string `json:"scy,omitempty"`
+	Net           string `json:"net"`
+	Type          string `json:"type"`
+	Host          string `json:"host"`
+	Path          string `json:"path"`
+	TLS           string `json:"tls"`
+	SNI           string `json:"sni,omitempty"`
+	Alpn          string `json:"alpn,omitempty"`
+	Fp            string `json:"fp,omitempty"`
+	AllowInsecure bool   `json:"allowInsecure"`
+}
+
+type singBoxMultiplexer struct {
+	Enabled bool `json:"enabled"`
+}
+
+// host returns the host of the transport. sing-box uses a string for httpupgrade and a list for http.
+func (t *singBoxTransport) host() string {
+	if len(t.Host) > 0 {
+		var host string
+		if err := json.Unmarshal(t.Host, &host); err == nil {
+			return host
+		}
+		var hosts []string
+		if err := json.Unmarshal(t.Host, &hosts); err == nil && len(hosts) > 0 {
+			return hosts[0]
+		}
+	}
+	for k, v := range t.Headers {
+		if strings.EqualFold(k, "host") {
+			return v
+		}
+	}
+	return ""
+}
+
+func (t *singBoxTls) serverName(server string) string {
+	if t.ServerName != "" {
+		return t.ServerName
+	}
+	return server
+}
+
+func (t *singBoxTls) isReality() bool {
+	return t != nil && t.Enabled && t.Reality != nil && t.Reality.Enabled
+}
+
+func (t *singBoxTls) fingerprint() string {
+	if t == nil || t.Utls == nil || !t.Utls.Enabled {
+		return ""
+	}
+	return t.Utls.Fingerprint
+}
+
+// singBoxPseudoOutbounds are outbounds that do not represent a proxy server.
+var singBoxPseudoOutbounds = common.StringSet([]string{
+	"selector", "urltest", "direct", "block", "dns",
+})
+
+func ResolveSubscriptionAsSingBox(log *logrus.Logger, b []byte) (nodes []string, err error) {
+	log.Debugln("Try to resolve as sing-box")
+
+	var outbounds []singBoxOutbound
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		if err = json.Unmarshal(b, &outbounds); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal json to sing-box outbounds")
+		}
+	} else {
+		var conf singBox
+		if err = json.Unmarshal(b, &conf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal json to sing-box outbounds")
+		}
+		outbounds = conf.Outbounds
+	}
+	if len(outbounds) == 0 {
+		return nil, fmt.Errorf("does not seems like a sing-box subscription")
+	}
+	for _, out := range outbounds {
+		if _, ok := singBoxPseudoOutbounds[out.Type]; ok {
+			continue
+		}
+		link, err := out.toLink()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"tag":  out.Tag,
+				"type": out.Type,
+			}).Infof("skip sing-box outbound: %v", err)
+			continue
+		}
+		nodes = append(nodes, link)
+	}
+	return nodes, nil
+}
+
+func (o *singBoxOutbound) toLink() (string, error) {
+	if o.Server == "" || o.ServerPort == 0 {
+		return "", fmt.Errorf("server and server_port are required")
+	}
+	if o.Detour != "" {
+		return "", fmt.Errorf("detour is not supported")
+	}
+	if o.Multiplex != nil && o.Multiplex.Enabled {
+		return "", fmt.Errorf("multiplex is not supported")
+	}
+	host := net.JoinHostPort(o.Server, strconv.Itoa(o.ServerPort))
+	switch o.Type {
+	case "shadowsocks":
+		q := url.Values{}
+		if o.Plugin != "" {
+			// The separator is required by the SIP003 parser even if there is no option.
+			q.Set("plugin", o.Plugin+";"+o.PluginOpts)
+		}
+		u := url.URL{
+			Scheme:   "ss",
+			User:     url.User(base64.RawURLEncoding.EncodeToString([]byte(o.Method + ":" + o.Password))),
+			Host:     host,
+			RawQuery: q.Encode(),
+			Fragment: o.Tag,
+		}
+		return u.String(), nil
+	case "vmess":
+		return o.vmessLink()
+	case "vless":
+		return o.vlessLink(host)
+	case "trojan":
+		return o.trojanLink(host)
+	case "hysteria2":
+		if o.Obfs != nil && o.Obfs.Type != "" {
+			return "", fmt.Errorf("obfs %v is not supported", o.Obfs.Type)
+		}
+		q := url.Values{}
+		if o.Tls != nil {
+			common.SetValue(&q, "sni", o.Tls.ServerName)
+			if o.Tls.Insecure {
+				q.Set("insecure", "1")
+			}
+		}
+		if o.UpMbps > 0 && o.DownMbps > 0 {
+			// Convert Mbps to bytes per second.
+			q.Set("maxTx", strconv.FormatUint(uint64(o.UpMbps)*125000, 10))
+			q.Set("maxRx", strconv.FormatUint(uint64(o.DownMbps)*125000, 10))
+		}
+		u := url.URL{
+			Scheme:   "hysteria2",
+			User:     url.User(o.Password),
+			Host:     host,
+			RawQuery: q.Encode(),
+			Fragment: o.Tag,
+		}
+		return u.String(), nil
+	case "tuic":
+		q := url.Values{}
+		common.SetValue(&q, "congestion_control", o.CongestionControl)
+		common.SetValue(&q, "udp_relay_mode", o.UdpRelayMode)
+		if o.Tls != nil {
+			common.SetValue(&q, "sni", o.Tls.ServerName)
+			common.SetValue(&q, "alpn", strings.Join(o.Tls.Alpn, ","))
+			if o.Tls.Insecure {
+				q.Set("allow_insecure", "1")
+			}
+		}
+		u := url.URL{
+			Scheme:   "tuic",
+			User:     url.UserPassword(o.Uuid, o.Password),
+			Host:     host,
+			RawQuery: q.Encode(),
+			Fragment: o.Tag,
+		}
+		return u.String(), nil
+	case "anytls":
+		q := url.Values{}
+		if o.Tls != nil {
+			common.SetValue(&q, "sni", o.Tls.ServerName)
+			if o.Tls.Insecure {
+				q.Set("insecure", "1")
+			}
+		}
+		u := url.URL{
+			Scheme:   "anytls",
+			User:     url.User(o.Password),
+			Host:     host,
+			RawQuery: q.Encode(),
+			Fragment: o.Tag,
+		}
+		return u.String(), nil
+	case "socks":
+		if o.Version != "" && o.Version != "5" {
+			return "", fmt.Errorf("socks version %v is not supported", o.Version)
+		}
+		u := url.URL{
+			Scheme:   "socks5",
+			Host:     host,
+			Fragment: o.Tag,
+		}
+		if o.Username != "" {
+			u.User = url.UserPassword(o.Username, o.Password)
+		}
+		return u.String(), nil
+	case "http":
+		u := url.URL{
+			Scheme:   "http",
+			Host:     host,
+			Fragment: o.Tag,
+		}
+		if o.Tls != nil && o.Tls.Enabled {
+			u.Scheme = "https"
+			q := url.Values{}
+			common.SetValue(&q, "sni", o.Tls.ServerName)
+			if o.Tls.Insecure {
+				q.Set("allowInsecure", "1")
+			}
+			u.RawQuery = q.Encode()
+		}
+		if o.Username != "" {
+			u.User = url.UserPassword(o.Username, o.Password)
+		}
+		return u.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported outbound type")
+	}
+}
+
+// transportParams returns the network and the V2Ray style params of the transport.
+func (o *singBoxOutbound) transportParams() (network string, host string, path string, err error) {
+	if o.Transport == nil || o.Transport.Type == "" {
+		return "tcp", "", "", nil
+	}
+	switch o.Transport.Type {
+	case "ws", "httpupgrade":
+		return o.Transport.Type, o.Transport.host(), o.Transport.Path, nil
+	case "http":
+		return "h2", o.Transport.host(), o.Transport.Path, nil
+	case "grpc":
+		return "grpc", "", o.Transport.ServiceName, nil
+	default:
+		return "", "", "", fmt.Errorf("transport %v is not supported", o.Transport.Type)
+	}
+}
+
+func (o *singBoxOutbound) vmessLink() (string, error) {
+	if o.AlterId != 0 {
+		return "", fmt.Errorf("alter_id %v is not supported; we only support AEAD encryption", o.AlterId)
+	}
+	if o.Tls.isReality() {
+		return "", fmt.Errorf("only VLESS supports reality")
+	}
+	network, host, path, err := o.transportParams()
+	if err != nil {
+		return "", err
+	}
+	v := singBoxVmess{
+		V:    "2",
+		Ps:   o.Tag,
+		Add:  o.Server,
+		Port: strconv.Itoa(o.ServerPort),
+		ID:   o.Uuid,
+		Aid:  "0",
+		Scy:  o.Security,
+		Net:  network,
+		Type: "none",
+		Host: host,
+		Path: path,
+	}
+	if o.Tls != nil && o.Tls.Enabled {
+		v.TLS = "tls"
+		v.SNI = o.Tls.ServerName
+		v.Alpn = strings.Join(o.Tls.Alpn, ",")
+		v.Fp = o.Tls.fingerprint()
+		v.AllowInsecure = o.Tls.Insecure
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (o *singBoxOutbound) vlessLink(host string) (string, error) {
+	network, transportHost, path, err := o.transportParams()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("type", network)
+	common.SetValue(&q, "host", transportHost)
+	if network == "grpc" {
+		common.SetValue(&q, "serviceName", path)
+	} else {
+		common.SetValue(&q, "path", path)
+	}
+	common.SetValue(&q, "flow", o.Flow)
+	if o.Tls != nil && o.Tls.Enabled {
+		q.Set("security", "tls")
+		q.Set("sni", o.Tls.serverName(o.Server))
+		common.SetValue(&q, "alpn", strings.Join(o.Tls.Alpn, ","))
+		common.SetValue(&q, "fp", o.Tls.fingerprint())
+		if o.Tls.isReality() {
+			q.Set("security", "reality")
+			q.Set("pbk", o.Tls.Reality.PublicKey)
+			common.SetValue(&q, "sid", o.Tls.Reality.ShortId)
+		}
+	} else {
+		q.Set("security", "none")
+	}
+	u := url.URL{
+		Scheme:   "vless",
+		User:     url.User(o.Uuid),
+		Host:     host,
+		RawQuery: q.Encode(),
+		Fragment: o.Tag,
+	}
+	return u.String(), nil
+}
+
+func (o *singBoxOutbound) trojanLink(host string) (string, error) {
+	if o.Tls.isReality() {
+		return "", fmt.Errorf("only VLESS supports reality")
+	}
+	network, transportHost, path, err := o.transportParams()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	if o.Tls != nil {
+		common.SetValue(&q, "sni", o.Tls.ServerName)
+		common.SetValue(&q, "alpn", strings.Join(o.Tls.Alpn, ","))
+		common.SetValue(&q, "fp", o.Tls.fingerprint())
+		if o.Tls.Insecure {
+			q.Set("allowInsecure", "1")
+		}
+	}
+	if network != "tcp" {
+		q.Set("type", network)
+		common.SetValue(&q, "host", transportHost)
+		if network == "grpc" {
+			common.SetValue(&q, "serviceName", path)
+		} else {
+			common.SetValue(&q, "path", path)
+		}
+	}
+	u := url.URL{
+		Scheme:   "trojan",
+		User:     url.User(o.Password),
+		Host:     host,
+		RawQuery: q.Encode(),
+		Fragment: o.Tag,
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,99 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	D "github.com/daeuniverse/outbound/dialer"
+	_ "github.com/daeuniverse/outbound/dialer/hysteria2"
+	_ "github.com/daeuniverse/outbound/dialer/shadowsocks"
+	_ "github.com/daeuniverse/outbound/dialer/trojan"
+	_ "github.com/daeuniverse/outbound/dialer/tuic"
+	_ "github.com/daeuniverse/outbound/dialer/v2ray"
+	"github.com/daeuniverse/outbound/protocol/direct"
+	_ "github.com/daeuniverse/outbound/protocol/hysteria2"
+	_ "github.com/daeuniverse/outbound/protocol/shadowsocks"
+	_ "github.com/daeuniverse/outbound/protocol/trojanc"
+	_ "github.com/daeuniverse/outbound/protocol/tuic"
+	_ "github.com/daeuniverse/outbound/protocol/vless"
+	_ "github.com/daeuniverse/outbound/protocol/vmess"
+	_ "github.com/daeuniverse/outbound/transport/tls"
+	_ "github.com/daeuniverse/outbound/transport/ws"
+	"github.com/sirupsen/logrus"
+)
+
+const testSingBox = `{
+  "outbounds": [
+    {"type": "selector", "tag": "proxy", "outbounds": ["hk-ss", "jp-vless"]},
+    {"type": "urltest", "tag": "auto", "outbounds": ["hk-ss", "jp-vless"]},
+    {"type": "direct", "tag": "direct"},
+    {"type": "shadowsocks", "tag": "hk-ss", "server": "1.2.3.4", "server_port": 8388, "method": "aes-128-gcm", "password": "pass"},
+    {"type": "vless", "tag": "jp-vless", "server": "jp.example.com", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "flow": "xtls-rprx-vision",
+      "tls": {"enabled": true, "server_name": "www.example.com", "utls": {"enabled": true, "fingerprint": "chrome"},
+        "reality": {"enabled": true, "public_key": "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", "short_id": "0123"}}},
+    {"type": "vmess", "tag": "us-vmess", "server": "us.example.com", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811",
+      "tls": {"enabled": true, "server_name": "us.example.com", "utls": {"enabled": true, "fingerprint": "firefox"}}, "transport": {"type": "ws", "path": "/ws", "headers": {"Host": "us.example.com"}}},
+    {"type": "trojan", "tag": "sg-trojan", "server": "sg.example.com", "server_port": 443, "password": "pass",
+      "tls": {"enabled": true, "insecure": true, "alpn": ["h2", "http/1.1"], "utls": {"enabled": true, "fingerprint": "safari"}}},
+    {"type": "hysteria2", "tag": "tw-hy2", "server": "5.6.7.8", "server_port": 443, "password": "pass", "up_mbps": 100, "down_mbps": 500, "tls": {"enabled": true}},
+    {"type": "tuic", "tag": "kr-tuic", "server": "9.10.11.12", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "password": "pass", "tls": {"enabled": true, "alpn": ["h3"]}},
+    {"type": "wireguard", "tag": "unsupported", "server": "wg.example.com", "server_port": 51820}
+  ]
+}`
+
+func TestResolveSubscriptionAsSingBox(t *testing.T) {
+	nodes, err := ResolveSubscriptionAsSingBox(logrus.StandardLogger(), []byte(testSingBox))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"hk-ss", "jp-vless", "us-vmess", "sg-trojan", "tw-hy2", "kr-tuic"}
+	if len(nodes) != len(expected) {
+		t.Fatalf("expected %v nodes, got %v: %v", len(expected), len(nodes), nodes)
+	}
+	for i, node := range nodes {
+		_, p, err := D.NewNetproxyDialerFromLink(direct.SymmetricDirect, &D.ExtraOption{TlsImplementation: "tls"}, node)
+		if err != nil {
+			t.Fatalf("%v: %v", node, err)
+		}
+		if p.Name != expected[i] {
+			t.Errorf("expected name %v, got %v", expected[i], p.Name)
+		}
+	}
+}
+
+func TestResolveSubscriptionAsSingBox_Utls(t *testing.T) {
+	nodes, err := ResolveSubscriptionAsSingBox(logrus.StandardLogger(), []byte(testSingBox))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmess, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(nodes[2], "vmess://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		link     string
+		expected []string
+	}{
+		{nodes[1], []string{"fp=chrome"}},
+		{string(vmess), []string{`"fp":"firefox"`}},
+		{nodes[3], []string{"fp=safari", "alpn=h2%2Chttp%2F1.1"}},
+	} {
+		for _, e := range c.expected {
+			if !strings.Contains(c.link, e) {
+				t.Errorf("expected %v in %v", e, c.link)
+			}
+		}
+	}
+}
+
+func TestResolveSubscriptionAsSingBox_NotSingBox(t *testing.T) {
+	if _, err := ResolveSubscriptionAsSingBox(logrus.StandardLogger(), []byte(`{"version": 1, "servers": []}`)); err == nil {
+		t.Fatal("expected error for a SIP008 document")
+	}
+}
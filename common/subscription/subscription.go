@@ -6,8 +6,8 @@
 package subscription
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,6 +21,7 @@ import (
 
 	"github.com/daeuniverse/dae/common"
 	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/sirupsen/logrus"
 )
 
@@ -67,16 +68,22 @@ func ResolveSubscriptionAsBase64(log *logrus.Logger, b []byte) (nodes []string)
 	return nodes
 }
 
-func ResolveSubscriptionAsSIP008(log *logrus.Logger, b []byte) (nodes []string, err error) {
+func ResolveSubscriptionAsSIP008(log *logrus.Logger, b []byte) (nodes []string, info *Userinfo, err error) {
 	log.Debugln("Try to resolve as sip008")
 
 	var sip sip008
 	err = json.Unmarshal(b, &sip)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal json to sip008")
+		return nil, nil, fmt.Errorf("failed to unmarshal json to sip008")
 	}
 	if sip.Version != 1 || sip.Servers == nil {
-		return nil, fmt.Errorf("does not seems like a standard sip008 subscription")
+		return nil, nil, fmt.Errorf("does not seems like a standard sip008 subscription")
+	}
+	if sip.BytesUsed > 0 || sip.BytesRemaining > 0 {
+		info = &Userinfo{
+			Download: sip.BytesUsed,
+			Total:    sip.BytesUsed + sip.BytesRemaining,
+		}
 	}
 	for _, server := range sip.Servers {
 		u := url.URL{
@@ -88,7 +95,7 @@ func ResolveSubscriptionAsSIP008(log *logrus.Logger, b []byte) (nodes []string,
 		}
 		nodes = append(nodes, u.String())
 	}
-	return nodes, nil
+	return nodes, info, nil
 }
 
 func ResolveFile(u *url.URL, configDir string) (b []byte, err error) {
@@ -118,41 +125,39 @@ func ResolveFile(u *url.URL, configDir string) (b []byte, err error) {
 	if fi.Mode()&0037 > 0 {
 		return nil, fmt.Errorf("permissions %04o for '%v' are too open; requires the file is NOT writable by the same group and NOT accessible by others; suggest 0640 or 0600", fi.Mode()&0777, path)
 	}
-	// Resolve the first line instruction.
-	fReader := bufio.NewReader(f)
-	b, err = fReader.Peek(1)
-	if err != nil {
-		return nil, err
-	}
-	if string(b[0]) == "@" {
-		// Instruction line. But not support yet.
-		_, _, err = fReader.ReadLine()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	b, err = io.ReadAll(fReader)
+	b, err = io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
 	return bytes.TrimSpace(b), err
 }
 
-func ResolveSubscription(log *logrus.Logger, client *http.Client, configDir string, subscription string) (tag string, nodes []string, err error) {
+func readPersistFile(u *url.URL, configDir string, tag string) ([]byte, error) {
+	u.Host = "persist.d/" + tag + ".sub"
+	u.Path = ""
+	return ResolveFile(u, configDir)
+}
+
+// ResolveSubscription resolves the subscription to nodes. info is the traffic and expiry metadata if the provider gives it.
+// annotation is declared by the instruction line of a subscription file and should be applied to all nodes.
+// Fetching will be retried for given times with backoff until ctx is done.
+func ResolveSubscription(ctx context.Context, log *logrus.Logger, client *http.Client, configDir string, subscription string, retry int) (tag string, nodes []string, info *Userinfo, annotation []*config_parser.Param, err error) {
 	/// Get tag.
 	tag, subscription = common.GetTagFromLinkLikePlaintext(subscription)
 
 	/// Parse url.
 	u, err := url.Parse(subscription)
 	if err != nil {
-		return tag, nil, fmt.Errorf("failed to parse subscription \"%v\": %w", subscription, err)
+		return tag, nil, nil, nil, fmt.Errorf("failed to parse subscription \"%v\": %w", subscription, err)
 	}
 	log.Debugf("ResolveSubscription: %v", subscription)
 	var (
-		b    []byte
-		req  *http.Request
-		resp *http.Response
+		b           []byte
+		req         *http.Request
+		resp        *http.Response
+		meta        *persistMeta
+		persistPath string
+		inst        *Instruction
 	)
 
 	persistToFile := false
@@ -161,71 +166,156 @@ func ResolveSubscription(log *logrus.Logger, client *http.Client, configDir stri
 	case "file":
 		b, err = ResolveFile(u, configDir)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, nil, err
+		}
+		// Only local files are trusted to declare the instruction line.
+		if inst, b, err = SplitInstruction(b); err != nil {
+			return "", nil, nil, nil, fmt.Errorf("bad instruction line: %w", err)
 		}
 		goto resolve
 	case "http-file", "https-file":
 		if len(tag) == 0 {
-			return "", nil, fmt.Errorf("tag is required for http-file/https-file subscription")
+			return "", nil, nil, nil, fmt.Errorf("tag is required for http-file/https-file subscription")
 		}
 		persistToFile = true
+		persistPath = filepath.Join(configDir, "persist.d", tag+".sub")
 		subscription = strings.Replace(subscription, "-file", "", 1)
 		break
 	default:
 	}
-	req, err = http.NewRequest("GET", subscription, nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", subscription, nil)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, nil, err
 	}
 	req.Header.Set("User-Agent", fmt.Sprintf("dae/%v (like v2rayA/1.0 WebRequestHelper) (like v2rayN/1.0 WebRequestHelper)", config.Version))
-	resp, err = client.Do(req)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	if persistToFile {
+		if _, err = os.Stat(persistPath); err == nil {
+			// Make a conditional request only if the persisted body exists.
+			if meta = readPersistMeta(persistPath + PersistMetaSuffix); meta != nil {
+				if meta.ETag != "" {
+					req.Header.Set("If-None-Match", meta.ETag)
+				}
+				if meta.LastModified != "" {
+					req.Header.Set("If-Modified-Since", meta.LastModified)
+				}
+			}
+		}
+	}
+	resp, err = doWithRetry(log, client, req, retry)
+	if err == nil && resp.StatusCode != http.StatusNotModified && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		resp.Body.Close()
+		err = fmt.Errorf("bad status: %v", resp.Status)
+	}
 	if err != nil {
 		if persistToFile {
-			log.Warnln("failed to fetch subscription, try to read from file")
-			u.Host = "persist.d/" + tag + ".sub"
-			u.Path = ""
-			b, err = ResolveFile(u, configDir)
-
-			if err != nil {
-				return "", nil, err
+			log.Warnf("failed to fetch subscription: %v; try to read from file", err)
+			if b, err = readPersistFile(u, configDir, tag); err != nil {
+				return "", nil, nil, nil, err
+			}
+			if meta != nil {
+				info = meta.Userinfo
 			}
 			goto resolve
 		}
 
-		return "", nil, err
+		return "", nil, nil, nil, err
 	}
 	defer resp.Body.Close()
-	b, err = io.ReadAll(resp.Body)
+	if v := resp.Header.Get(UserinfoHeader); v != "" {
+		if info, err = ParseUserinfo(v); err != nil {
+			log.Debugf("failed to parse %v header: %v", UserinfoHeader, err)
+		}
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		if !persistToFile {
+			return "", nil, nil, nil, fmt.Errorf("unexpected status: %v", resp.Status)
+		}
+		log.Debugf("Subscription %v is not modified; reuse the persisted one", tag)
+		if b, err = readPersistFile(u, configDir, tag); err != nil {
+			return "", nil, nil, nil, err
+		}
+		if info == nil && meta != nil {
+			info = meta.Userinfo
+		}
+		goto resolve
+	}
+	b, err = readBody(resp)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, nil, err
 	}
 
 	if persistToFile {
-		path := filepath.Join(configDir, "persist.d")
+		path := filepath.Dir(persistPath)
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			err := os.MkdirAll(path, 0700)
 			if err != nil {
-				return "", nil, err
+				return "", nil, nil, nil, err
 			}
 		}
 
-		path = filepath.Join(path, tag+".sub")
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		file, err := os.OpenFile(persistPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, nil, err
 		}
 		defer file.Close()
 
 		_, err = file.Write(b)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, nil, err
+		}
+		if err = writePersistMeta(persistPath+PersistMetaSuffix, &persistMeta{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Userinfo:     info,
+		}); err != nil {
+			log.Debugf("failed to write persist meta: %v", err)
 		}
 	}
 resolve:
-	if nodes, err = ResolveSubscriptionAsSIP008(log, b); err == nil {
-		return tag, nodes, nil
+	if inst == nil {
+		inst = &Instruction{}
+	}
+	nodes, contentInfo, err := resolveContent(log, b, inst.Format)
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	if info == nil {
+		info = contentInfo
+	}
+	if inst.Prefix != "" {
+		for i := range nodes {
+			if n, err := ParseNode(nodes[i]); err == nil {
+				n.SetName(inst.Prefix + n.Name)
+				nodes[i] = n.Link
+			}
+		}
+	}
+	return tag, nodes, info, inst.Annotation, nil
+}
+
+// resolveContent resolves the content in given format. It tries every format if format is empty.
+func resolveContent(log *logrus.Logger, b []byte, format string) (nodes []string, info *Userinfo, err error) {
+	switch format {
+	case Format_SIP008:
+		return ResolveSubscriptionAsSIP008(log, b)
+	case Format_SingBox:
+		nodes, err = ResolveSubscriptionAsSingBox(log, b)
+		return nodes, nil, err
+	case Format_Base64:
+		return ResolveSubscriptionAsBase64(log, b), nil, nil
+	case Format_Plain:
+		return ResolveSubscriptionAsPlain(b), nil, nil
+	}
+	if nodes, info, err = ResolveSubscriptionAsSIP008(log, b); err == nil {
+		return nodes, info, nil
+	} else {
+		log.Debugln(err)
+	}
+	if nodes, err = ResolveSubscriptionAsSingBox(log, b); err == nil {
+		return nodes, nil, nil
 	} else {
 		log.Debugln(err)
 	}
-	return tag, ResolveSubscriptionAsBase64(log, b), nil
+	return ResolveSubscriptionAsBase64(log, b), nil, nil
 }
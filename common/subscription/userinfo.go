@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	UserinfoHeader = "Subscription-Userinfo"
+	// UserinfoFileName is the file in the config directory to store userinfo of subscriptions for "dae subscription".
+	UserinfoFileName = "subscription_info.json"
+)
+
+// Userinfo is the traffic and expiry metadata of a subscription.
+// Zero Total or Expire means unknown or unlimited.
+type Userinfo struct {
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+	Total    int64 `json:"total"`
+	// Expire is a unix timestamp in seconds.
+	Expire int64 `json:"expire"`
+}
+
+// ParseUserinfo parses header value like "upload=123; download=456; total=789; expire=1700000000".
+func ParseUserinfo(s string) (info *Userinfo, err error) {
+	info = &Userinfo{}
+	for _, field := range strings.Split(s, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+		// Some providers give float numbers.
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad %v: %v", key, val)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			info.Upload = int64(f)
+		case "download":
+			info.Download = int64(f)
+		case "total":
+			info.Total = int64(f)
+		case "expire":
+			info.Expire = int64(f)
+		}
+	}
+	return info, nil
+}
+
+func (u *Userinfo) Used() int64 {
+	return u.Upload + u.Download
+}
+
+// Remaining returns the remaining traffic. ok is false if total is unknown.
+func (u *Userinfo) Remaining() (remaining int64, ok bool) {
+	if u.Total <= 0 {
+		return 0, false
+	}
+	return u.Total - u.Used(), true
+}
+
+// ExpireAt returns the expiry time. ok is false if the subscription never expires.
+func (u *Userinfo) ExpireAt() (t time.Time, ok bool) {
+	if u.Expire <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(u.Expire, 0), true
+}
+
+// Warn logs warnings if remaining traffic is less than trafficPercent% of total or the subscription expires within expireWithin.
+func (u *Userinfo) Warn(log *logrus.Logger, tag string, trafficPercent uint8, expireWithin time.Duration) {
+	if remaining, ok := u.Remaining(); ok && trafficPercent > 0 {
+		if remaining*100 <= u.Total*int64(trafficPercent) {
+			log.WithFields(logrus.Fields{
+				"subscription": tag,
+				"remaining":    FormatBytes(max(remaining, 0)),
+				"total":        FormatBytes(u.Total),
+			}).Warnln("Subscription traffic is running out")
+		}
+	}
+	if expireAt, ok := u.ExpireAt(); ok && expireWithin > 0 {
+		if left := time.Until(expireAt); left <= 0 {
+			log.WithFields(logrus.Fields{
+				"subscription": tag,
+				"expire":       expireAt.Format(time.DateTime),
+			}).Warnln("Subscription has expired")
+		} else if left <= expireWithin {
+			log.WithFields(logrus.Fields{
+				"subscription": tag,
+				"expire":       expireAt.Format(time.DateTime),
+				"left":         left.Truncate(time.Minute).String(),
+			}).Warnln("Subscription is about to expire")
+		}
+	}
+}
+
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return strconv.FormatInt(b, 10) + "B"
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
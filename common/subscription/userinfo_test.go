@@ -0,0 +1,38 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package subscription
+
+import (
+	"testing"
+)
+
+func TestParseUserinfo(t *testing.T) {
+	info, err := ParseUserinfo("upload=1024; download=2048.0; total=10240; expire=1700000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Used() != 3072 || info.Total != 10240 || info.Expire != 1700000000 {
+		t.Fatalf("unexpected userinfo: %+v", info)
+	}
+	if remaining, ok := info.Remaining(); !ok || remaining != 7168 {
+		t.Fatalf("unexpected remaining: %v, %v", remaining, ok)
+	}
+
+	info, err = ParseUserinfo("upload=0; download=0; total=; expire=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.Remaining(); ok {
+		t.Fatal("remaining should be unknown")
+	}
+	if _, ok := info.ExpireAt(); ok {
+		t.Fatal("expire should be unknown")
+	}
+
+	if _, err = ParseUserinfo("upload=abc"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -303,6 +303,13 @@ func FuzzyDecode(to interface{}, val string) bool {
 		}
 	case reflect.String:
 		v.SetString(val)
+	case reflect.Pointer:
+		// Pointer indicates whether the value is set.
+		elem := reflect.New(v.Type().Elem())
+		if !FuzzyDecode(elem.Interface(), val) {
+			return false
+		}
+		v.Set(elem)
 	case reflect.Struct:
 		switch v.Interface().(type) {
 		case UrlOrEmpty:
@@ -7,9 +7,12 @@ package dialer
 
 import (
 	"fmt"
+	"hash/fnv"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/daeuniverse/dae/common/consts"
@@ -42,10 +45,18 @@ type AliveDialerSet struct {
 	dialerToIndex           map[*Dialer]int // *Dialer -> index of inorderedAliveDialerSet
 	dialerToLatency         map[*Dialer]time.Duration
 	dialerToLatencyOffset   map[*Dialer]time.Duration
+	dialerToWeight          map[*Dialer]uint
+	dialerToPriority        map[*Dialer][2]int // (priority, index in the group)
+	dialerToAliveSince      map[*Dialer]time.Time
 	inorderedAliveDialerSet []*Dialer
 
 	selectionPolicy consts.DialerSelectionPolicy
+	maxLoss         float64
 	minLatency      minLatency
+	failoverDialer  *Dialer
+
+	// lastUsed is the unix nano time of the last selection, which is used to check idle groups less often.
+	lastUsed atomic.Int64
 }
 
 func NewAliveDialerSet(
@@ -54,6 +65,7 @@ func NewAliveDialerSet(
 	networkType *NetworkType,
 	tolerance time.Duration,
 	selectionPolicy consts.DialerSelectionPolicy,
+	maxLoss float64,
 	dialers []*Dialer,
 	dialersAnnotations []*Annotation,
 	aliveChangeCallback func(alive bool),
@@ -63,9 +75,16 @@ func NewAliveDialerSet(
 		panic(fmt.Sprintf("unmatched annotations length: %v dialers and %v annotations", len(dialers), len(dialersAnnotations)))
 	}
 	dialerToLatencyOffset := make(map[*Dialer]time.Duration)
+	dialerToWeight := make(map[*Dialer]uint)
+	dialerToPriority := make(map[*Dialer][2]int)
 	for i := range dialers {
 		d, a := dialers[i], dialersAnnotations[i]
 		dialerToLatencyOffset[d] = a.AddLatency
+		dialerToPriority[d] = [2]int{a.Priority, i}
+		dialerToWeight[d] = a.Weight
+		if a.Weight == 0 {
+			dialerToWeight[d] = 1
+		}
 	}
 	a := &AliveDialerSet{
 		log:                     log,
@@ -76,22 +95,40 @@ func NewAliveDialerSet(
 		dialerToIndex:           make(map[*Dialer]int),
 		dialerToLatency:         make(map[*Dialer]time.Duration),
 		dialerToLatencyOffset:   dialerToLatencyOffset,
+		dialerToWeight:          dialerToWeight,
+		dialerToPriority:        dialerToPriority,
+		dialerToAliveSince:      make(map[*Dialer]time.Time),
 		inorderedAliveDialerSet: make([]*Dialer, 0, len(dialers)),
 		selectionPolicy:         selectionPolicy,
+		maxLoss:                 maxLoss,
 		minLatency: minLatency{
 			// Initiate the latency with a very big value.
 			sortingLatency: time.Hour,
 		},
 	}
+	a.lastUsed.Store(time.Now().UnixNano())
 	for _, d := range dialers {
 		a.dialerToIndex[d] = -Init
 	}
 	for _, d := range dialers {
-		a.NotifyLatencyChange(d, setAlive)
+		// Respect the alive state restored from the check history.
+		a.NotifyLatencyChange(d, setAlive && d.mustGetCollection(networkType).Alive)
 	}
 	return a
 }
 
+// MarkUsed records that the group selects a dialer from the set for traffic.
+func (a *AliveDialerSet) MarkUsed() {
+	if a == nil {
+		return
+	}
+	a.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (a *AliveDialerSet) LastUsed() time.Time {
+	return time.Unix(0, a.lastUsed.Load())
+}
+
 func (a *AliveDialerSet) GetRand() *Dialer {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -102,6 +139,120 @@ func (a *AliveDialerSet) GetRand() *Dialer {
 	return a.inorderedAliveDialerSet[ind]
 }
 
+// GetFailover selects the alive dialer with the highest priority, which is the first one in the declaration order of
+// filters. It does not revert to a recovered dialer with higher priority until the dialer has been alive for
+// revertAfter, to avoid churn caused by flapping dialers.
+func (a *AliveDialerSet) GetFailover(revertAfter time.Duration) *Dialer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var best *Dialer
+	for _, d := range a.inorderedAliveDialerSet {
+		if best == nil {
+			best = d
+			continue
+		}
+		p, bestP := a.dialerToPriority[d], a.dialerToPriority[best]
+		if p[0] < bestP[0] || (p[0] == bestP[0] && p[1] < bestP[1]) {
+			best = d
+		}
+	}
+	old := a.failoverDialer
+	switch {
+	case best == nil:
+		a.failoverDialer = nil
+	case old == nil || a.dialerToIndex[old] < 0:
+		// No dialer selected or the selected dialer dies.
+		a.failoverDialer = best
+	case best != old && time.Since(a.dialerToAliveSince[best]) >= revertAfter:
+		a.failoverDialer = best
+	}
+	if a.failoverDialer != old && a.failoverDialer != nil {
+		oldDialerName := "<nil>"
+		if old != nil {
+			oldDialerName = old.property.Name
+		}
+		a.log.WithFields(logrus.Fields{
+			"_new_dialer": a.failoverDialer.property.Name,
+			"_old_dialer": oldDialerName,
+			"group":       a.dialerGroupName,
+			"network":     a.CheckTyp.String(),
+		}).Infof("Group fails over to dialer")
+	}
+	return a.failoverDialer
+}
+
+// GetBalanced selects an alive dialer randomly, weighted by the weight annotation, the inverse of sorting latency and
+// the inverse of connections in flight.
+func (a *AliveDialerSet) GetBalanced() *Dialer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.inorderedAliveDialerSet) == 0 {
+		return nil
+	}
+	weights := make([]float64, len(a.inorderedAliveDialerSet))
+	var total float64
+	for i, d := range a.inorderedAliveDialerSet {
+		latency, ok := a.dialerToLatency[d]
+		if !ok || latency <= 0 {
+			// Not checked yet.
+			latency = Timeout
+		}
+		latency += a.dialerToLatencyOffset[d]
+		if latency < time.Millisecond {
+			// Negative offset.
+			latency = time.Millisecond
+		}
+		weights[i] = float64(a.dialerToWeight[d]) / latency.Seconds() / float64(1+d.InFlight())
+		total += weights[i]
+	}
+	r := fastrand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return a.inorderedAliveDialerSet[i]
+		}
+		r -= w
+	}
+	return a.inorderedAliveDialerSet[len(a.inorderedAliveDialerSet)-1]
+}
+
+// GetConsistent selects an alive dialer for the key by rendezvous hashing. The result of a key only changes when its
+// dialer dies or a dialer with higher score for the key becomes alive.
+func (a *AliveDialerSet) GetConsistent(key string) *Dialer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var (
+		best      *Dialer
+		bestScore uint64
+	)
+	for _, d := range a.inorderedAliveDialerSet {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(d.property.Link))
+		score := mix64(h.Sum64())
+		if best == nil || score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best
+}
+
+// mix64 is the finalizer of splitmix64, which spreads fnv hashes of similar inputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (a *AliveDialerSet) IsAlive(d *Dialer) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dialerToIndex[d] >= 0
+}
+
 func (a *AliveDialerSet) SortingLatency(d *Dialer) time.Duration {
 	return a.dialerToLatency[d] + a.dialerToLatencyOffset[d]
 }
@@ -111,6 +262,32 @@ func (a *AliveDialerSet) GetMinLatency() (d *Dialer, latency time.Duration) {
 	return a.minLatency.dialer, a.minLatency.sortingLatency
 }
 
+// GetMinLatencyExcept selects the alive dialer with the minimum sorting latency except the given dialers, which is used
+// to retry after the selected dialer fails to dial.
+func (a *AliveDialerSet) GetMinLatencyExcept(except []*Dialer) *Dialer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var (
+		best        *Dialer
+		bestLatency time.Duration
+	)
+	for _, d := range a.inorderedAliveDialerSet {
+		if slices.Contains(except, d) {
+			continue
+		}
+		latency, ok := a.dialerToLatency[d]
+		if !ok || latency <= 0 {
+			// Not checked yet.
+			latency = Timeout
+		}
+		latency += a.dialerToLatencyOffset[d]
+		if best == nil || latency < bestLatency {
+			best, bestLatency = d, latency
+		}
+	}
+	return best
+}
+
 func (a *AliveDialerSet) printLatencies() {
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("Group '%v' [%v]:\n", a.dialerGroupName, a.CheckTyp.String()))
@@ -158,7 +335,29 @@ func (a *AliveDialerSet) NotifyLatencyChange(dialer *Dialer, alive bool) {
 	case consts.DialerSelectionPolicy_MinAverage10Latencies:
 		rawLatency, hasLatency = dialer.mustGetCollection(a.CheckTyp).Latencies10.AvgLatency()
 		minPolicy = true
-	case consts.DialerSelectionPolicy_MinMovingAverageLatencies:
+	case consts.DialerSelectionPolicy_MinAverage10WithLoss:
+		probes := dialer.mustGetCollection(a.CheckTyp).Probes10
+		// Failed checks count in loss instead of latency.
+		rawLatency, hasLatency = probes.AvgLatency()
+		loss, probed := probes.Loss()
+		if !hasLatency && probed {
+			rawLatency, hasLatency = Timeout, true
+		}
+		if alive && probed && loss > a.maxLoss {
+			// Exclude the lossy dialer as if it is not alive.
+			if a.dialerToIndex[dialer] >= 0 {
+				a.log.WithFields(logrus.Fields{
+					"dialer":   dialer.property.Name,
+					"group":    a.dialerGroupName,
+					"loss":     fmt.Sprintf("%.0f%%", loss*100),
+					"max_loss": fmt.Sprintf("%.0f%%", a.maxLoss*100),
+				}).Infof("Exclude lossy dialer")
+			}
+			alive = false
+		}
+		minPolicy = true
+	case consts.DialerSelectionPolicy_MinMovingAverageLatencies,
+		consts.DialerSelectionPolicy_Balance:
 		rawLatency = dialer.mustGetCollection(a.CheckTyp).MovingAverage
 		hasLatency = rawLatency > 0
 		minPolicy = true
@@ -177,6 +376,7 @@ func (a *AliveDialerSet) NotifyLatencyChange(dialer *Dialer, alive bool) {
 				}).Infof("[NOT ALIVE --%v-> ALIVE]", a.CheckTyp.String())
 			}
 			a.dialerToIndex[dialer] = len(a.inorderedAliveDialerSet)
+			a.dialerToAliveSince[dialer] = time.Now()
 			a.inorderedAliveDialerSet = append(a.inorderedAliveDialerSet, dialer)
 		}
 	} else {
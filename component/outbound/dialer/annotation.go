@@ -7,6 +7,7 @@ package dialer
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/daeuniverse/dae/pkg/config_parser"
@@ -14,10 +15,16 @@ import (
 
 const (
 	AnnotationKey_AddLatency = "add_latency"
+	AnnotationKey_Weight     = "weight"
 )
 
 type Annotation struct {
 	AddLatency time.Duration
+	// Weight is used by the balance policy. Zero means the default weight 1.
+	Weight uint
+	// Priority is the index of the first filter the dialer hits, which is used by the failover policy. It is not set
+	// by annotations.
+	Priority int
 }
 
 func NewAnnotation(annotation []*config_parser.Param) (*Annotation, error) {
@@ -33,6 +40,15 @@ func NewAnnotation(annotation []*config_parser.Param) (*Annotation, error) {
 			if anno.AddLatency == 0 {
 				anno.AddLatency = latency
 			}
+		case AnnotationKey_Weight:
+			weight, err := strconv.ParseUint(param.Val, 10, 16)
+			if err != nil || weight == 0 {
+				return nil, fmt.Errorf("incorrect weight format: expect a positive integer: %v", param.Val)
+			}
+			// Only the first setting is valid.
+			if anno.Weight == 0 {
+				anno.Weight = uint(weight)
+			}
 		default:
 			return nil, fmt.Errorf("unknown filter annotation: %v", param.Key)
 		}
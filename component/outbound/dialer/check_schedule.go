@@ -0,0 +1,98 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/outbound/pkg/fastrand"
+)
+
+const (
+	// MinCheckInterval is the lower bound of the interval to check failing or recovering dialers.
+	MinCheckInterval = 5 * time.Second
+	// MaxIdleCheckBackoff is the max factor of check_interval to check dialers in idle groups.
+	MaxIdleCheckBackoff = 8
+	// IdleCheckRounds is the number of check intervals without traffic, after which a group is considered idle.
+	IdleCheckRounds = 10
+)
+
+var (
+	checkSemMu sync.Mutex
+	// checkSem limits concurrent checks across all dialers.
+	checkSem chan struct{}
+)
+
+// acquireCheck waits for a free slot of concurrent checks. concurrency 0 indicates no limit.
+func acquireCheck(ctx context.Context, concurrency int) (release func(), ok bool) {
+	if concurrency <= 0 {
+		return func() {}, true
+	}
+	checkSemMu.Lock()
+	if cap(checkSem) != concurrency {
+		// Concurrency changes after reloading. Checks holding the old one release to it.
+		checkSem = make(chan struct{}, concurrency)
+	}
+	sem := checkSem
+	checkSemMu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// nextCheckInterval decides when to check again. Dialers failing or recovering are checked more often, and dialers
+// only in groups that have not carried traffic recently are checked less often.
+func (d *Dialer) nextCheckInterval(opts []*CheckOption) time.Duration {
+	cycle := d.CheckInterval
+	unstable := false
+	idleAfter := time.Duration(IdleCheckRounds) * cycle
+	// Dialers selected by groups of policy fixed are not in any alive dialer set.
+	idle := time.Since(d.LastUsed()) >= idleAfter
+	d.collectionFineMu.Lock()
+	for _, opt := range opts {
+		collection := d.mustGetCollection(opt.networkType)
+		if len(collection.AliveDialerSetSet) == 0 {
+			continue
+		}
+		if loss, ok := collection.Probes10.Loss(); !collection.Alive || (ok && loss > 0) {
+			unstable = true
+		}
+		for a := range collection.AliveDialerSetSet {
+			if time.Since(a.LastUsed()) < idleAfter {
+				idle = false
+			}
+		}
+	}
+	d.collectionFineMu.Unlock()
+
+	var interval time.Duration
+	switch {
+	case unstable:
+		d.idleRounds = 0
+		interval = cycle / 4
+		if interval < MinCheckInterval {
+			interval = min(cycle, MinCheckInterval)
+		}
+	case idle:
+		if d.idleRounds < MaxIdleCheckBackoff {
+			d.idleRounds++
+		}
+		interval = cycle * time.Duration(d.idleRounds)
+	default:
+		d.idleRounds = 0
+		interval = cycle
+	}
+	// Jitter by 10% to spread checks of dialers.
+	if jitter := int64(interval / 5); jitter > 0 {
+		interval += time.Duration(fastrand.Int63n(jitter)) - interval/10
+	}
+	return interval
+}
@@ -0,0 +1,135 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/sirupsen/logrus"
+)
+
+var testCheckNetworkType = &NetworkType{
+	L4Proto:   consts.L4ProtoStr_TCP,
+	IpVersion: consts.IpVersionStr_4,
+	IsDns:     false,
+}
+
+func newTestCheckDialer(checkInterval time.Duration) (*Dialer, []*CheckOption) {
+	d := NewDialer(nil, &GlobalOption{Log: logrus.New(), CheckInterval: checkInterval}, InstanceOption{DisableCheck: true}, &Property{})
+	return d, []*CheckOption{{networkType: testCheckNetworkType}}
+}
+
+// expectInterval checks the interval is jittered within 10% of want.
+func expectInterval(t *testing.T, interval time.Duration, want time.Duration) {
+	t.Helper()
+	if interval < want-want/10 || interval >= want+want/10 {
+		t.Fatalf("interval %v is out of %v±10%%", interval, want)
+	}
+}
+
+func TestNextCheckInterval_Unstable(t *testing.T) {
+	d, opts := newTestCheckDialer(time.Minute)
+	a := &AliveDialerSet{}
+	a.MarkUsed()
+	collection := d.mustGetCollection(testCheckNetworkType)
+	collection.AliveDialerSetSet[a] = 1
+	collection.Alive = false
+	for i := 0; i < 100; i++ {
+		expectInterval(t, d.nextCheckInterval(opts), time.Minute/4)
+	}
+
+	// A quarter of the cycle is clamped to MinCheckInterval.
+	d.CheckInterval = 10 * time.Second
+	for i := 0; i < 100; i++ {
+		expectInterval(t, d.nextCheckInterval(opts), MinCheckInterval)
+	}
+	// But it is never longer than the cycle.
+	d.CheckInterval = 2 * time.Second
+	expectInterval(t, d.nextCheckInterval(opts), 2*time.Second)
+}
+
+func TestNextCheckInterval_IdleBackoff(t *testing.T) {
+	d, opts := newTestCheckDialer(time.Minute)
+	a := &AliveDialerSet{}
+	d.mustGetCollection(testCheckNetworkType).AliveDialerSetSet[a] = 1
+	// Neither the group nor the dialer has been used.
+	d.lastUsed.Store(0)
+	for i := 1; i <= MaxIdleCheckBackoff+2; i++ {
+		expectInterval(t, d.nextCheckInterval(opts), time.Minute*time.Duration(min(i, MaxIdleCheckBackoff)))
+	}
+
+	// Traffic of the group resets the backoff.
+	a.MarkUsed()
+	expectInterval(t, d.nextCheckInterval(opts), time.Minute)
+}
+
+func TestNextCheckInterval_Fixed(t *testing.T) {
+	// Dialers only in groups of policy fixed have no alive dialer set.
+	d, opts := newTestCheckDialer(time.Minute)
+	d.lastUsed.Store(0)
+	expectInterval(t, d.nextCheckInterval(opts), time.Minute)
+	expectInterval(t, d.nextCheckInterval(opts), 2*time.Minute)
+
+	d.MarkUsed()
+	expectInterval(t, d.nextCheckInterval(opts), time.Minute)
+}
+
+func TestAcquireCheck(t *testing.T) {
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, ok := acquireCheck(context.Background(), 2)
+		if !ok {
+			t.Fatal("failed to acquire a free slot")
+		}
+		releases = append(releases, release)
+	}
+
+	// The cap is reached.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := acquireCheck(ctx, 2); ok {
+		t.Fatal("expected to wait until ctx is done")
+	}
+	releases[0]()
+	release, ok := acquireCheck(context.Background(), 2)
+	if !ok {
+		t.Fatal("failed to acquire a released slot")
+	}
+	releases[0] = release
+
+	// Concurrency changes after reloading.
+	release, ok = acquireCheck(context.Background(), 3)
+	if !ok {
+		t.Fatal("failed to acquire a slot after resizing")
+	}
+	if cap(checkSem) != 3 {
+		t.Fatalf("expected the semaphore to be resized to 3, got %v", cap(checkSem))
+	}
+	release()
+	// Old checks release to the old semaphore without blocking.
+	done := make(chan struct{})
+	go func() {
+		for _, release := range releases {
+			release()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("releasing to the old semaphore blocked")
+	}
+
+	// No limit.
+	for i := 0; i < 10; i++ {
+		if _, ok := acquireCheck(context.Background(), 0); !ok {
+			t.Fatal("expected no limit")
+		}
+	}
+}
@@ -58,13 +58,18 @@ type collection struct {
 	AliveDialerSetSet AliveDialerSetSet
 	Latencies10       *LatenciesN
 	MovingAverage     time.Duration
-	Alive             bool
+	// Probes10 keeps success or failure of last 10 checks, for loss and jitter.
+	Probes10 *ProbesN
+	Alive    bool
+
+	passive passiveHealth
 }
 
 func newCollection() *collection {
 	return &collection{
 		AliveDialerSetSet: make(AliveDialerSetSet),
 		Latencies10:       NewLatenciesN(10),
+		Probes10:          NewProbesN(10),
 		Alive:             true,
 	}
 }
@@ -437,19 +442,23 @@ func (d *Dialer) aliveBackground() {
 
 	ctx, cancel := context.WithCancel(d.ctx)
 	defer cancel()
+	// Delay randomly to avoid avalanche.
+	d.tickerMu.Lock()
+	d.timer = time.NewTimer(cycle + time.Duration(fastrand.Int63n(int64(cycle))))
+	timer := d.timer
+	d.tickerMu.Unlock()
 	go func() {
-		/// Splice ticker.C to checkCh.
-		// Sleep to avoid avalanche.
-		time.Sleep(time.Duration(fastrand.Int63n(int64(cycle))))
-		d.tickerMu.Lock()
-		d.ticker = time.NewTicker(cycle)
-		d.tickerMu.Unlock()
-		for t := range d.ticker.C {
+		/// Splice timer.C to checkCh.
+		for {
 			select {
 			case <-ctx.Done():
 				return
-			default:
-				d.checkCh <- t
+			case t := <-timer.C:
+				select {
+				case d.checkCh <- t:
+				default:
+					// A check is pending.
+				}
 			}
 		}
 	}()
@@ -475,12 +484,20 @@ func (d *Dialer) aliveBackground() {
 
 			wg.Add(1)
 			go func(opt *CheckOption) {
+				defer wg.Done()
+				release, ok := acquireCheck(ctx, d.CheckConcurrency)
+				if !ok {
+					return
+				}
+				defer release()
 				_, _ = d.Check(opt)
-				wg.Done()
 			}(opt)
 		}
 		// Wait to block the loop.
 		wg.Wait()
+		d.tickerMu.Lock()
+		timer.Reset(d.nextCheckInterval(CheckOpts))
+		d.tickerMu.Unlock()
 	}
 }
 
@@ -503,6 +520,10 @@ func (d *Dialer) MustGetLatencies10(typ *NetworkType) *LatenciesN {
 	return d.mustGetCollection(typ).Latencies10
 }
 
+func (d *Dialer) MustGetProbes10(typ *NetworkType) *ProbesN {
+	return d.mustGetCollection(typ).Probes10
+}
+
 // RegisterAliveDialerSet is thread-safe.
 func (d *Dialer) RegisterAliveDialerSet(a *AliveDialerSet) {
 	if a == nil {
@@ -547,8 +568,11 @@ func (d *Dialer) logUnavailable(
 		}).Debugln("Connectivity Check Failed")
 	}
 	collection.Latencies10.AppendLatency(Timeout)
+	collection.Probes10.AppendFailure()
+	d.collectionFineMu.Lock()
 	collection.MovingAverage = (collection.MovingAverage + Timeout) / 2
 	collection.Alive = false
+	d.collectionFineMu.Unlock()
 }
 
 func (d *Dialer) informDialerGroupUpdate(collection *collection) {
@@ -577,16 +601,25 @@ func (d *Dialer) Check(opts *CheckOption) (ok bool, err error) {
 		// No error.
 		latency := time.Since(start)
 		collection.Latencies10.AppendLatency(latency)
+		collection.Probes10.AppendSuccess(latency)
 		avg, _ := collection.Latencies10.AvgLatency()
+		loss, _ := collection.Probes10.Loss()
+		jitter, _ := collection.Probes10.Jitter()
+		d.collectionFineMu.Lock()
 		collection.MovingAverage = (collection.MovingAverage + latency) / 2
-		collection.Alive = true
+		// Keep it not alive until the circuit opened by real traffic is closed.
+		collection.Alive = !collection.passive.checkPassed()
+		movingAverage := collection.MovingAverage
+		d.collectionFineMu.Unlock()
 
 		d.Log.WithFields(logrus.Fields{
 			"network": opts.networkType.String(),
 			"node":    d.property.Name,
 			"last":    latency.Truncate(time.Millisecond).String(),
 			"avg_10":  avg.Truncate(time.Millisecond),
-			"mov_avg": collection.MovingAverage.Truncate(time.Millisecond),
+			"mov_avg": movingAverage.Truncate(time.Millisecond),
+			"loss_10": fmt.Sprintf("%.0f%%", loss*100),
+			"jitter":  jitter.Truncate(time.Millisecond),
 		}).Debugln("Connectivity Check")
 	} else {
 		d.logUnavailable(collection, opts.networkType, err)
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -34,12 +35,18 @@ type Dialer struct {
 	collections      [6]*collection
 
 	tickerMu sync.Mutex
-	ticker   *time.Ticker
+	timer    *time.Timer
 	checkCh  chan time.Time
 	ctx      context.Context
 	cancel   context.CancelFunc
 
 	checkActivated bool
+	// idleRounds is the number of rounds checked in idle groups, which is used to back off.
+	idleRounds int
+
+	inFlight atomic.Int64
+	// lastUsed is the unix nano time of the last selection by groups without alive dialer sets, such as policy fixed.
+	lastUsed atomic.Int64
 }
 
 type GlobalOption struct {
@@ -49,7 +56,9 @@ type GlobalOption struct {
 	CheckDnsOptionRaw CheckDnsOptionRaw // Lazy parse
 	CheckInterval     time.Duration
 	CheckTolerance    time.Duration
+	CheckConcurrency  int
 	CheckDnsTcp       bool
+	Mptcp             bool
 }
 
 type InstanceOption struct {
@@ -81,7 +90,9 @@ func NewGlobalOption(global *config.Global, log *logrus.Logger) *GlobalOption {
 		CheckDnsOptionRaw: CheckDnsOptionRaw{Raw: global.UdpCheckDns, ResolverNetwork: common.MagicNetwork("udp", global.SoMarkFromDae, global.Mptcp), Somark: global.SoMarkFromDae},
 		CheckInterval:     global.CheckInterval,
 		CheckTolerance:    global.CheckTolerance,
+		CheckConcurrency:  int(global.CheckConcurrency),
 		CheckDnsTcp:       true,
+		Mptcp:             global.Mptcp,
 	}
 }
 
@@ -100,11 +111,12 @@ func NewDialer(dialer netproxy.Dialer, option *GlobalOption, iOption InstanceOpt
 		collectionFineMu: sync.Mutex{},
 		collections:      collections,
 		tickerMu:         sync.Mutex{},
-		ticker:           nil,
+		timer:            nil,
 		checkCh:          make(chan time.Time, 1),
 		ctx:              ctx,
 		cancel:           cancel,
 	}
+	d.lastUsed.Store(time.Now().UnixNano())
 	option.Log.WithField("dialer", d.Property().Name).
 		WithField("p", unsafe.Pointer(d)).
 		Traceln("NewDialer")
@@ -118,8 +130,8 @@ func (d *Dialer) Clone() *Dialer {
 func (d *Dialer) Close() error {
 	d.cancel()
 	d.tickerMu.Lock()
-	if d.ticker != nil {
-		d.ticker.Stop()
+	if d.timer != nil {
+		d.timer.Stop()
 	}
 	d.tickerMu.Unlock()
 	return nil
@@ -128,3 +140,27 @@ func (d *Dialer) Close() error {
 func (d *Dialer) Property() *Property {
 	return d.property
 }
+
+// InFlight returns the number of connections in flight through the dialer.
+func (d *Dialer) InFlight() int64 {
+	return d.inFlight.Load()
+}
+
+// MarkUsed records that a group without alive dialer sets selects the dialer for traffic.
+func (d *Dialer) MarkUsed() {
+	d.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (d *Dialer) LastUsed() time.Time {
+	return time.Unix(0, d.lastUsed.Load())
+}
+
+// AcquireInFlight counts a connection in flight through the dialer until the returned release is called.
+// It is safe to call release more than once.
+func (d *Dialer) AcquireInFlight() (release func()) {
+	d.inFlight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { d.inFlight.Add(-1) })
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PassiveFailureThreshold is the number of distinct targets of consecutive failures of real traffic to open the
+	// circuit. Failures to the same target are counted once, because they are usually caused by the target.
+	PassiveFailureThreshold = 3
+	// PassiveMinBackoff is the duration the circuit keeps open at the first time. It doubles every time the circuit
+	// opens again before real traffic succeeds, up to the check interval.
+	PassiveMinBackoff = 5 * time.Second
+)
+
+// passiveHealth observes real traffic through a dialer. Consecutive failures to enough distinct targets open the
+// circuit, which marks the dialer not alive until the backoff expires and a connectivity check passes.
+type passiveHealth struct {
+	mu sync.Mutex
+	// failedHosts are hosts of targets of consecutive failures.
+	failedHosts map[string]struct{}
+	backoff     time.Duration
+	openUntil   time.Time
+}
+
+// checkPassed reports whether the circuit is still open when a connectivity check passes. The backoff is reset if
+// the circuit has closed, because a node marked not alive gets no real traffic to reset it.
+func (p *passiveHealth) checkPassed() (open bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.openUntil) {
+		return true
+	}
+	p.backoff = 0
+	p.openUntil = time.Time{}
+	return false
+}
+
+// ReportDialFailure reports a failure of real traffic to target, such as a failed dial or write.
+func (d *Dialer) ReportDialFailure(typ *NetworkType, target string, err error) {
+	host, _, e := net.SplitHostPort(target)
+	if e != nil {
+		host = target
+	}
+	collection := d.mustGetCollection(typ)
+	p := &collection.passive
+	p.mu.Lock()
+	if p.failedHosts == nil {
+		p.failedHosts = make(map[string]struct{})
+	}
+	p.failedHosts[host] = struct{}{}
+	if len(p.failedHosts) < PassiveFailureThreshold || time.Now().Before(p.openUntil) {
+		p.mu.Unlock()
+		return
+	}
+	clear(p.failedHosts)
+	if p.backoff == 0 {
+		p.backoff = PassiveMinBackoff
+	} else {
+		p.backoff *= 2
+	}
+	if p.backoff > d.CheckInterval && d.CheckInterval >= PassiveMinBackoff {
+		p.backoff = d.CheckInterval
+	}
+	backoff := p.backoff
+	p.openUntil = time.Now().Add(backoff)
+	p.mu.Unlock()
+
+	d.Log.WithFields(logrus.Fields{
+		"network": typ.String(),
+		"node":    d.property.Name,
+		"backoff": backoff.String(),
+		"err":     err,
+	}).Warnln("Too many failures of traffic; mark the node not alive")
+	// Only flip the alive state. Check history is kept for latency and loss based policies.
+	d.collectionFineMu.Lock()
+	collection.Alive = false
+	d.collectionFineMu.Unlock()
+	d.informDialerGroupUpdate(collection)
+	// Check again after the backoff.
+	time.AfterFunc(backoff, d.NotifyCheck)
+}
+
+// ReportDialSuccess reports a success of real traffic, which resets failures and the backoff. It also closes the
+// circuit if it is open.
+func (d *Dialer) ReportDialSuccess(typ *NetworkType) {
+	collection := d.mustGetCollection(typ)
+	p := &collection.passive
+	p.mu.Lock()
+	clear(p.failedHosts)
+	if p.backoff == 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.backoff = 0
+	wasOpen := time.Now().Before(p.openUntil)
+	p.openUntil = time.Time{}
+	p.mu.Unlock()
+
+	if !wasOpen {
+		return
+	}
+	d.collectionFineMu.Lock()
+	wasAlive := collection.Alive
+	collection.Alive = true
+	d.collectionFineMu.Unlock()
+	if !wasAlive {
+		d.informDialerGroupUpdate(collection)
+	}
+}
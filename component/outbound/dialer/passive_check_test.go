@@ -0,0 +1,60 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPassiveHealth_CheckHistory(t *testing.T) {
+	d, opts := newTestCheckDialer(15 * time.Second)
+	defer d.Close()
+	typ := opts[0].networkType
+	collection := d.mustGetCollection(typ)
+	for i := 0; i < PassiveFailureThreshold; i++ {
+		d.ReportDialFailure(typ, fmt.Sprintf("192.0.2.%v:443", i), fmt.Errorf("test"))
+	}
+	if collection.Alive {
+		t.Fatal("expected the dialer not alive")
+	}
+	// The circuit opened by real traffic should not distort the check history.
+	if _, ok := collection.Probes10.Loss(); ok {
+		t.Error("unexpected probes recorded by real traffic")
+	}
+	if _, ok := collection.Latencies10.LastLatency(); ok || collection.MovingAverage != 0 {
+		t.Error("unexpected latencies recorded by real traffic")
+	}
+
+	// A passed check keeps the dialer not alive until the backoff expires.
+	check := &CheckOption{
+		networkType: typ,
+		CheckFunc: func(ctx context.Context, typ *NetworkType) (ok bool, err error) {
+			return true, nil
+		},
+	}
+	if _, err := d.Check(check); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Alive {
+		t.Fatal("expected the dialer not alive before the backoff expires")
+	}
+	collection.passive.mu.Lock()
+	collection.passive.openUntil = time.Now()
+	collection.passive.mu.Unlock()
+	if _, err := d.Check(check); err != nil {
+		t.Fatal(err)
+	}
+	if !collection.Alive {
+		t.Fatal("expected the dialer alive after the backoff expires")
+	}
+	// The node gets no real traffic while it is not alive, so the passed check resets the backoff.
+	if collection.passive.backoff != 0 {
+		t.Errorf("expected the backoff reset, got %v", collection.passive.backoff)
+	}
+}
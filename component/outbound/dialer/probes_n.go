@@ -0,0 +1,115 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+type probe struct {
+	latency time.Duration
+	ok      bool
+}
+
+// ProbesN keeps results of the last N checks, including failed ones, to calculate loss and jitter.
+type ProbesN struct {
+	N          int
+	LastNProbe *list.List
+
+	mu sync.Mutex
+}
+
+func NewProbesN(n int) *ProbesN {
+	return &ProbesN{
+		N:          n,
+		LastNProbe: list.New(),
+	}
+}
+
+func (pn *ProbesN) append(p probe) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if pn.LastNProbe.Len() >= pn.N {
+		pn.LastNProbe.Remove(pn.LastNProbe.Front())
+	}
+	pn.LastNProbe.PushBack(p)
+}
+
+// AppendSuccess appends a successful check with its latency.
+//
+// It is thread-safe.
+func (pn *ProbesN) AppendSuccess(latency time.Duration) {
+	pn.append(probe{latency: latency, ok: true})
+}
+
+// AppendFailure appends a failed check.
+//
+// It is thread-safe.
+func (pn *ProbesN) AppendFailure() {
+	pn.append(probe{})
+}
+
+// Loss returns the ratio of failed checks in range [0, 1].
+func (pn *ProbesN) Loss() (float64, bool) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if pn.LastNProbe.Len() == 0 {
+		return 0, false
+	}
+	var failed int
+	for e := pn.LastNProbe.Front(); e != nil; e = e.Next() {
+		if !e.Value.(probe).ok {
+			failed++
+		}
+	}
+	return float64(failed) / float64(pn.LastNProbe.Len()), true
+}
+
+// AvgLatency returns the average latency of successful checks.
+func (pn *ProbesN) AvgLatency() (time.Duration, bool) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	var (
+		sum time.Duration
+		n   int
+	)
+	for e := pn.LastNProbe.Front(); e != nil; e = e.Next() {
+		if p := e.Value.(probe); p.ok {
+			sum += p.latency
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / time.Duration(n), true
+}
+
+// Jitter returns the standard deviation of latencies of successful checks.
+func (pn *ProbesN) Jitter() (time.Duration, bool) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	var (
+		sum, sumSquare float64
+		n              int
+	)
+	for e := pn.LastNProbe.Front(); e != nil; e = e.Next() {
+		if p := e.Value.(probe); p.ok {
+			l := float64(p.latency)
+			sum += l
+			sumSquare += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	mean := sum / float64(n)
+	return time.Duration(math.Sqrt(math.Max(sumSquare/float64(n)-mean*mean, 0))), true
+}
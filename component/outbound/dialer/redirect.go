@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"context"
+	"net/netip"
+
+	D "github.com/daeuniverse/outbound/dialer"
+	"github.com/daeuniverse/outbound/netproxy"
+)
+
+const RedirectProtocol = "redirect"
+
+// redirectDialer dials the fixed target directly whatever the destination is.
+type redirectDialer struct {
+	direct netproxy.Dialer
+	target string
+}
+
+// NewRedirectDialer creates a dialer for outbound redirect, which rewrites the destination of traffic to target.
+func NewRedirectDialer(option *GlobalOption, target string) (netproxy.Dialer, *Property) {
+	direct, _ := NewDirectDialer(option, false)
+	return &redirectDialer{
+		direct: direct,
+		target: target,
+	}, &Property{
+		Property: D.Property{
+			Name:     RedirectProtocol + "(" + target + ")",
+			Address:  target,
+			Protocol: RedirectProtocol,
+		},
+		SubscriptionTag: "",
+	}
+}
+
+func (d *redirectDialer) DialContext(ctx context.Context, network, addr string) (netproxy.Conn, error) {
+	magicNetwork, err := netproxy.ParseMagicNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.direct.DialContext(ctx, network, d.target)
+	if err != nil {
+		return nil, err
+	}
+	if magicNetwork.Network == "udp" {
+		// Responses should look like from the original destination, otherwise clients will not accept them.
+		origDst, err := netip.ParseAddrPort(addr)
+		if err != nil {
+			return conn, nil
+		}
+		return &redirectPacketConn{
+			PacketConn: conn.(netproxy.PacketConn),
+			origDst:    origDst,
+		}, nil
+	}
+	return conn, nil
+}
+
+// redirectPacketConn is a connected packet conn to the redirect target.
+type redirectPacketConn struct {
+	netproxy.PacketConn
+	origDst netip.AddrPort
+}
+
+func (c *redirectPacketConn) ReadFrom(p []byte) (int, netip.AddrPort, error) {
+	n, _, err := c.PacketConn.ReadFrom(p)
+	return n, c.origDst, err
+}
+
+func (c *redirectPacketConn) WriteTo(b []byte, addr string) (int, error) {
+	return c.PacketConn.Write(b)
+}
@@ -7,6 +7,7 @@ package dialer
 
 import (
 	D "github.com/daeuniverse/outbound/dialer"
+	"github.com/daeuniverse/outbound/netproxy"
 	"github.com/daeuniverse/outbound/protocol/direct"
 )
 
@@ -21,3 +22,28 @@ func NewFromLink(gOption *GlobalOption, iOption InstanceOption, link string, sub
 	}
 	return NewDialer(d, gOption, iOption, &p), nil
 }
+
+// CloneVia creates a new dialer from the link of the dialer, which dials through via instead of directly. The name of
+// the dialer is kept.
+func (d *Dialer) CloneVia(via netproxy.Dialer) (*Dialer, error) {
+	return d.cloneFromLink(d.GlobalOption, via)
+}
+
+// CloneWithOption creates a new dialer from the link of the dialer with another option. Unlike Clone, ExtraOption of
+// the option takes effect. The name of the dialer is kept.
+func (d *Dialer) CloneWithOption(option *GlobalOption) (*Dialer, error) {
+	return d.cloneFromLink(option, direct.SymmetricDirect)
+}
+
+func (d *Dialer) cloneFromLink(option *GlobalOption, parent netproxy.Dialer) (*Dialer, error) {
+	nd, _p, err := D.NewNetproxyDialerFromLink(parent, &option.ExtraOption, d.property.Link)
+	if err != nil {
+		return nil, err
+	}
+	p := Property{
+		Property:        *_p,
+		SubscriptionTag: d.property.SubscriptionTag,
+	}
+	p.Name = d.property.Name
+	return NewDialer(nd, option, d.InstanceOption, &p), nil
+}
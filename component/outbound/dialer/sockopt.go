@@ -139,3 +139,16 @@ func bindAddr(fd uintptr, addrPort netip.AddrPort) error {
 
 	return syscall.Bind(int(fd), sockAddr)
 }
+
+func BindToDeviceControl(c syscall.RawConn, iface string) error {
+	var sockOptErr error
+	controlErr := c.Control(func(fd uintptr) {
+		if err := unix.BindToDevice(int(fd), iface); err != nil {
+			sockOptErr = fmt.Errorf("error setting SO_BINDTODEVICE socket option: %w", err)
+		}
+	})
+	if controlErr != nil {
+		return fmt.Errorf("error invoking socket control function: %w", controlErr)
+	}
+	return sockOptErr
+}
@@ -0,0 +1,80 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"time"
+
+	"github.com/daeuniverse/dae/common/consts"
+)
+
+// CollectionState is the persistent check history of a dialer for a network type.
+type CollectionState struct {
+	Latencies10   []time.Duration `json:"latencies_10"`
+	MovingAverage time.Duration   `json:"moving_average"`
+	Alive         bool            `json:"alive"`
+}
+
+// State is the persistent check history of a dialer, indexed by the string of network type.
+type State map[string]*CollectionState
+
+// stateNetworkTypes are network types of collections of a dialer.
+var stateNetworkTypes = []*NetworkType{
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_4, IsDns: true},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_6, IsDns: true},
+	{L4Proto: consts.L4ProtoStr_UDP, IpVersion: consts.IpVersionStr_4, IsDns: true},
+	{L4Proto: consts.L4ProtoStr_UDP, IpVersion: consts.IpVersionStr_6, IsDns: true},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_4, IsDns: false},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_6, IsDns: false},
+}
+
+// State returns the check history of collections that have been checked.
+func (d *Dialer) State() State {
+	state := make(State)
+	for _, typ := range stateNetworkTypes {
+		collection := d.mustGetCollection(typ)
+		collection.Latencies10.mu.Lock()
+		latencies := make([]time.Duration, 0, collection.Latencies10.LastNLatencies.Len())
+		for e := collection.Latencies10.LastNLatencies.Front(); e != nil; e = e.Next() {
+			latencies = append(latencies, e.Value.(time.Duration))
+		}
+		collection.Latencies10.mu.Unlock()
+		if len(latencies) == 0 {
+			continue
+		}
+		// Checks and passive reports update them under collectionFineMu.
+		d.collectionFineMu.Lock()
+		state[typ.String()] = &CollectionState{
+			Latencies10:   latencies,
+			MovingAverage: collection.MovingAverage,
+			Alive:         collection.Alive,
+		}
+		d.collectionFineMu.Unlock()
+	}
+	return state
+}
+
+// RestoreState restores the check history. It should be called before the dialer is added to any group.
+func (d *Dialer) RestoreState(state State) {
+	for _, typ := range stateNetworkTypes {
+		s, ok := state[typ.String()]
+		if !ok || s == nil {
+			continue
+		}
+		collection := d.mustGetCollection(typ)
+		for _, latency := range s.Latencies10 {
+			collection.Latencies10.AppendLatency(latency)
+			// Failed checks are recorded as Timeout.
+			if latency >= Timeout {
+				collection.Probes10.AppendFailure()
+			} else {
+				collection.Probes10.AppendSuccess(latency)
+			}
+		}
+		collection.MovingAverage = s.MovingAverage
+		collection.Alive = s.Alive
+	}
+}
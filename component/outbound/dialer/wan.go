@@ -0,0 +1,141 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/outbound/common"
+	D "github.com/daeuniverse/outbound/dialer"
+	"github.com/daeuniverse/outbound/netproxy"
+)
+
+const WanProtocol = "direct"
+
+// wanDialer dials directly through a given interface by SO_BINDTODEVICE, or routes traffic by a given fwmark.
+type wanDialer struct {
+	iface string
+	mark  uint32
+}
+
+// NewWanDialer creates a direct dialer for a WAN defined in section "wan".
+func NewWanDialer(wan *config.Wan) (netproxy.Dialer, *Property, error) {
+	if wan.Iface == "" && wan.Mark == 0 {
+		return nil, nil, fmt.Errorf(`wan "%v": at least one of "iface" and "mark" should be given`, wan.Name)
+	}
+	return &wanDialer{
+		iface: wan.Iface,
+		mark:  wan.Mark,
+	}, &Property{
+		Property: D.Property{
+			Name:     wan.Name,
+			Protocol: WanProtocol,
+			// Link is used to distinguish WANs in check history.
+			Link: "wan://" + wan.Name,
+		},
+		SubscriptionTag: "",
+	}, nil
+}
+
+func (d *wanDialer) control(mark uint32) func(network, address string, c syscall.RawConn) error {
+	if d.mark != 0 {
+		mark = d.mark
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		if mark != 0 {
+			if err := SoMarkControl(c, int(mark)); err != nil {
+				return err
+			}
+		}
+		if d.iface != "" {
+			if err := BindToDeviceControl(c, d.iface); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// resolver resolves domains through the WAN too, otherwise they may be resolved through another interface.
+func (d *wanDialer) resolver(mark uint32) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer := net.Dialer{Control: d.control(mark)}
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+func (d *wanDialer) DialContext(ctx context.Context, network, addr string) (netproxy.Conn, error) {
+	magicNetwork, err := netproxy.ParseMagicNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	switch magicNetwork.Network {
+	case "tcp":
+		dialer := net.Dialer{
+			Control:  d.control(magicNetwork.Mark),
+			Resolver: d.resolver(magicNetwork.Mark),
+		}
+		dialer.SetMultipathTCP(magicNetwork.Mptcp)
+		return dialer.DialContext(ctx, "tcp", addr)
+	case "udp":
+		lc := net.ListenConfig{Control: d.control(magicNetwork.Mark)}
+		conn, err := lc.ListenPacket(ctx, "udp", "")
+		if err != nil {
+			return nil, err
+		}
+		return &wanPacketConn{
+			UDPConn:  conn.(*net.UDPConn),
+			dialTgt:  addr,
+			resolver: d.resolver(magicNetwork.Mark),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %v", netproxy.UnsupportedTunnelTypeError, network)
+	}
+}
+
+// wanPacketConn is a full-cone packet conn, which is the same as the built-in direct dialer.
+type wanPacketConn struct {
+	*net.UDPConn
+	dialTgt       string
+	cachedDialTgt netip.AddrPort
+	resolver      *net.Resolver
+}
+
+func (c *wanPacketConn) ReadFrom(p []byte) (int, netip.AddrPort, error) {
+	return c.UDPConn.ReadFromUDPAddrPort(p)
+}
+
+func (c *wanPacketConn) WriteTo(b []byte, addr string) (int, error) {
+	uAddr, err := common.ResolveUDPAddr(c.resolver, addr)
+	if err != nil {
+		return 0, err
+	}
+	return c.UDPConn.WriteTo(b, uAddr)
+}
+
+func (c *wanPacketConn) Read(b []byte) (int, error) {
+	n, _, err := c.UDPConn.ReadFrom(b)
+	return n, err
+}
+
+func (c *wanPacketConn) Write(b []byte) (int, error) {
+	if !c.cachedDialTgt.IsValid() {
+		ua, err := common.ResolveUDPAddr(c.resolver, c.dialTgt)
+		if err != nil {
+			return 0, err
+		}
+		c.cachedDialTgt = ua.AddrPort()
+	}
+	return c.UDPConn.WriteToUDPAddrPort(b, c.cachedDialTgt)
+}
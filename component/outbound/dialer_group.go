@@ -8,6 +8,7 @@ package outbound
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/daeuniverse/dae/common/consts"
@@ -30,6 +31,12 @@ type DialerGroup struct {
 	aliveDialerSets [6]*dialer.AliveDialerSet
 
 	selectionPolicy *DialerSelectionPolicy
+	sticky          *stickyTable
+	// selected is the dialer selected at runtime for policy select.
+	selected atomic.Pointer[dialer.Dialer]
+
+	// OnFail is the group to dial through when all retried dialers of this group fail, which is given by "on_fail".
+	OnFail *DialerGroup
 }
 
 func NewDialerGroup(
@@ -50,11 +57,20 @@ func NewDialerGroup(
 
 	var needAliveState bool
 
-	switch p.Policy {
+	// Policy select falls back to another policy, which decides how to check dialers.
+	alivePolicy, maxLoss := p.Policy, p.MaxLoss
+	if p.Policy == consts.DialerSelectionPolicy_Select {
+		alivePolicy, maxLoss = p.Fallback.Policy, p.Fallback.MaxLoss
+	}
+	switch alivePolicy {
 	case consts.DialerSelectionPolicy_Random,
 		consts.DialerSelectionPolicy_MinLastLatency,
 		consts.DialerSelectionPolicy_MinAverage10Latencies,
-		consts.DialerSelectionPolicy_MinMovingAverageLatencies:
+		consts.DialerSelectionPolicy_MinAverage10WithLoss,
+		consts.DialerSelectionPolicy_MinMovingAverageLatencies,
+		consts.DialerSelectionPolicy_Sticky,
+		consts.DialerSelectionPolicy_Balance,
+		consts.DialerSelectionPolicy_Failover:
 		// Need to know the alive state or latency.
 		needAliveState = true
 
@@ -73,7 +89,7 @@ func NewDialerGroup(
 	}
 	if needAliveState {
 		aliveTcp4DialerSet = dialer.NewAliveDialerSet(
-			log, name, networkType, option.CheckTolerance, p.Policy, dialers, dialersAnnotations,
+			log, name, networkType, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations,
 			func(networkType *dialer.NetworkType) func(alive bool) {
 				// Use the trick to copy a pointer of *dialer.NetworkType.
 				return func(alive bool) { aliveChangeCallback(alive, networkType, false) }
@@ -88,7 +104,7 @@ func NewDialerGroup(
 	}
 	if needAliveState {
 		aliveTcp6DialerSet = dialer.NewAliveDialerSet(
-			log, name, networkType, option.CheckTolerance, p.Policy, dialers, dialersAnnotations,
+			log, name, networkType, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations,
 			func(networkType *dialer.NetworkType) func(alive bool) {
 				// Use the trick to copy a pointer of *dialer.NetworkType.
 				return func(alive bool) { aliveChangeCallback(alive, networkType, false) }
@@ -103,7 +119,7 @@ func NewDialerGroup(
 	}
 	if needAliveState {
 		aliveDnsUdp4DialerSet = dialer.NewAliveDialerSet(
-			log, name, networkType, option.CheckTolerance, p.Policy, dialers, dialersAnnotations,
+			log, name, networkType, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations,
 			func(networkType *dialer.NetworkType) func(alive bool) {
 				// Use the trick to copy a pointer of *dialer.NetworkType.
 				return func(alive bool) { aliveChangeCallback(alive, networkType, false) }
@@ -118,7 +134,7 @@ func NewDialerGroup(
 	}
 	if needAliveState {
 		aliveDnsUdp6DialerSet = dialer.NewAliveDialerSet(
-			log, name, networkType, option.CheckTolerance, p.Policy, dialers, dialersAnnotations,
+			log, name, networkType, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations,
 			func(networkType *dialer.NetworkType) func(alive bool) {
 				// Use the trick to copy a pointer of *dialer.NetworkType.
 				return func(alive bool) { aliveChangeCallback(alive, networkType, false) }
@@ -131,13 +147,13 @@ func NewDialerGroup(
 			L4Proto:   consts.L4ProtoStr_TCP,
 			IpVersion: consts.IpVersionStr_4,
 			IsDns:     true,
-		}, option.CheckTolerance, p.Policy, dialers, dialersAnnotations, func(alive bool) {}, true)
+		}, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations, func(alive bool) {}, true)
 
 		aliveDnsTcp6DialerSet = dialer.NewAliveDialerSet(log, name, &dialer.NetworkType{
 			L4Proto:   consts.L4ProtoStr_TCP,
 			IpVersion: consts.IpVersionStr_6,
 			IsDns:     true,
-		}, option.CheckTolerance, p.Policy, dialers, dialersAnnotations, func(alive bool) {}, true)
+		}, option.CheckTolerance, alivePolicy, maxLoss, dialers, dialersAnnotations, func(alive bool) {}, true)
 	}
 
 	for _, d := range dialers {
@@ -149,6 +165,11 @@ func NewDialerGroup(
 		d.RegisterAliveDialerSet(aliveDnsUdp6DialerSet)
 	}
 
+	var sticky *stickyTable
+	if p.Policy == consts.DialerSelectionPolicy_Sticky {
+		sticky = newStickyTable(p.StickyTtl)
+	}
+
 	return &DialerGroup{
 		log:     log,
 		Name:    name,
@@ -162,6 +183,7 @@ func NewDialerGroup(
 			aliveTcp6DialerSet,
 		},
 		selectionPolicy: &p,
+		sticky:          sticky,
 	}
 }
 
@@ -174,8 +196,12 @@ func (g *DialerGroup) Close() error {
 	return nil
 }
 
+// SetSelectionPolicy replaces the policy before the group is used. Alive dialer sets are created for the policy given
+// to NewDialerGroup, so it is not for switching policies at runtime; use SetSelected for policy select instead.
 func (g *DialerGroup) SetSelectionPolicy(policy DialerSelectionPolicy) {
-	// TODO:
+	if policy.Policy == consts.DialerSelectionPolicy_Sticky {
+		g.sticky = newStickyTable(policy.StickyTtl)
+	}
 	g.selectionPolicy = &policy
 }
 
@@ -183,6 +209,30 @@ func (g *DialerGroup) GetSelectionPolicy() (policy consts.DialerSelectionPolicy)
 	return g.selectionPolicy.Policy
 }
 
+// SetSelected selects the dialer with given name for policy select. Empty name clears the selection, and the group
+// selects by the fallback policy.
+func (g *DialerGroup) SetSelected(name string) error {
+	if g.selectionPolicy.Policy != consts.DialerSelectionPolicy_Select {
+		return fmt.Errorf("policy of group %v is %v instead of %v", g.Name, g.selectionPolicy.Policy, consts.DialerSelectionPolicy_Select)
+	}
+	if name == "" {
+		g.selected.Store(nil)
+		return nil
+	}
+	for _, d := range g.Dialers {
+		if d.Property().Name == name {
+			g.selected.Store(d)
+			return nil
+		}
+	}
+	return fmt.Errorf("node %v is not in group %v", name, g.Name)
+}
+
+// GetSelected returns the dialer selected for policy select, which is nil if not selected.
+func (g *DialerGroup) GetSelected() *dialer.Dialer {
+	return g.selected.Load()
+}
+
 func (d *DialerGroup) MustGetAliveDialerSet(typ *dialer.NetworkType) *dialer.AliveDialerSet {
 	if typ.IsDns {
 		switch typ.L4Proto {
@@ -225,11 +275,16 @@ func (d *DialerGroup) MustGetAliveDialerSet(typ *dialer.NetworkType) *dialer.Ali
 
 // Select selects a dialer from group according to selectionPolicy. If 'strictIpVersion' is false and no alive dialer, it will fallback to another ipversion.
 func (g *DialerGroup) Select(networkType *dialer.NetworkType, strictIpVersion bool) (d *dialer.Dialer, latency time.Duration, err error) {
+	return g.SelectFor(networkType, strictIpVersion, nil)
+}
+
+// SelectFor is like Select, but policies depending on the client (such as sticky) select by opt.
+func (g *DialerGroup) SelectFor(networkType *dialer.NetworkType, strictIpVersion bool, opt *SelectOption) (d *dialer.Dialer, latency time.Duration, err error) {
 	policy := g.selectionPolicy
-	d, latency, err = g._select(networkType, policy)
+	d, latency, err = g._select(networkType, policy, opt)
 	if !strictIpVersion && errors.Is(err, ErrNoAliveDialer) {
 		networkType.IpVersion = (consts.IpVersion_X - networkType.IpVersion.ToIpVersionType()).ToIpVersionStr()
-		return g._select(networkType, policy)
+		return g._select(networkType, policy, opt)
 	}
 	if err == nil {
 		return d, latency, nil
@@ -239,7 +294,7 @@ func (g *DialerGroup) Select(networkType *dialer.NetworkType, strictIpVersion bo
 		if d, _, err = g._select(networkType, &DialerSelectionPolicy{
 			Policy:     consts.DialerSelectionPolicy_Fixed,
 			FixedIndex: 0,
-		}); err != nil {
+		}, opt); err != nil {
 			return nil, 0, err
 		}
 		return d, dialer.Timeout, nil
@@ -247,11 +302,23 @@ func (g *DialerGroup) Select(networkType *dialer.NetworkType, strictIpVersion bo
 	return nil, latency, err
 }
 
-func (g *DialerGroup) _select(networkType *dialer.NetworkType, policy *DialerSelectionPolicy) (d *dialer.Dialer, latency time.Duration, err error) {
+// SelectAlternative selects the next best alive dialer except the tried ones, which is used to retry after dialing fails.
+// It returns nil if there is no other alive dialer.
+func (g *DialerGroup) SelectAlternative(networkType *dialer.NetworkType, tried []*dialer.Dialer) *dialer.Dialer {
+	a := g.MustGetAliveDialerSet(networkType)
+	if a == nil {
+		// Alive state is not maintained, e.g. policy fixed.
+		return nil
+	}
+	return a.GetMinLatencyExcept(tried)
+}
+
+func (g *DialerGroup) _select(networkType *dialer.NetworkType, policy *DialerSelectionPolicy, opt *SelectOption) (d *dialer.Dialer, latency time.Duration, err error) {
 	if len(g.Dialers) == 0 {
 		return nil, 0, fmt.Errorf("no dialer in this group")
 	}
 	a := g.MustGetAliveDialerSet(networkType)
+	a.MarkUsed()
 	switch policy.Policy {
 	case consts.DialerSelectionPolicy_Random:
 		d := a.GetRand()
@@ -265,10 +332,13 @@ func (g *DialerGroup) _select(networkType *dialer.NetworkType, policy *DialerSel
 		if g.selectionPolicy.FixedIndex < 0 || g.selectionPolicy.FixedIndex >= len(g.Dialers) {
 			return nil, 0, fmt.Errorf("selected dialer index is out of range")
 		}
-		return g.Dialers[g.selectionPolicy.FixedIndex], 0, nil
+		d = g.Dialers[g.selectionPolicy.FixedIndex]
+		d.MarkUsed()
+		return d, 0, nil
 
 	case consts.DialerSelectionPolicy_MinLastLatency,
 		consts.DialerSelectionPolicy_MinAverage10Latencies,
+		consts.DialerSelectionPolicy_MinAverage10WithLoss,
 		consts.DialerSelectionPolicy_MinMovingAverageLatencies:
 		d, latency := a.GetMinLatency()
 		if d == nil {
@@ -277,6 +347,37 @@ func (g *DialerGroup) _select(networkType *dialer.NetworkType, policy *DialerSel
 		}
 		return d, latency, nil
 
+	case consts.DialerSelectionPolicy_Select:
+		if d := g.selected.Load(); d != nil && a.IsAlive(d) {
+			return d, 0, nil
+		}
+		// Not selected or the selected dialer dies.
+		return g._select(networkType, policy.Fallback, opt)
+
+	case consts.DialerSelectionPolicy_Failover:
+		d := a.GetFailover(policy.RevertAfter)
+		if d == nil {
+			// No alive dialer.
+			return nil, time.Hour, ErrNoAliveDialer
+		}
+		return d, 0, nil
+
+	case consts.DialerSelectionPolicy_Balance:
+		d := a.GetBalanced()
+		if d == nil {
+			// No alive dialer.
+			return nil, time.Hour, ErrNoAliveDialer
+		}
+		return d, 0, nil
+
+	case consts.DialerSelectionPolicy_Sticky:
+		d := g.sticky.Get(a, opt.stickyKey(policy.StickyKey))
+		if d == nil {
+			// No alive dialer.
+			return nil, time.Hour, ErrNoAliveDialer
+		}
+		return d, 0, nil
+
 	default:
 		return nil, 0, fmt.Errorf("unsupported DialerSelectionPolicy: %v", g.selectionPolicy)
 	}
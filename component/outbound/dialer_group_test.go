@@ -6,6 +6,11 @@
 package outbound
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -13,6 +18,7 @@ import (
 	"github.com/daeuniverse/dae/component/outbound/dialer"
 	"github.com/daeuniverse/dae/pkg/logger"
 	"github.com/daeuniverse/outbound/pkg/fastrand"
+	"github.com/daeuniverse/outbound/protocol/direct"
 	"github.com/sirupsen/logrus"
 )
 
@@ -235,3 +241,416 @@ func TestDialerGroup_SetAlive(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestDialerGroup_Select_Sticky(t *testing.T) {
+
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	var (
+		dialers     []*dialer.Dialer
+		annotations []*dialer.Annotation
+	)
+	for i := 0; i < 5; i++ {
+		d := newDirectDialer(option, false)
+		d.Property().Link = fmt.Sprintf("direct://%v", i)
+		dialers = append(dialers, d)
+		annotations = append(annotations, &dialer.Annotation{})
+	}
+	g := NewDialerGroup(option, "test-group", dialers, annotations,
+		DialerSelectionPolicy{
+			Policy:    consts.DialerSelectionPolicy_Sticky,
+			StickyKey: consts.StickyKey_SrcIp,
+			StickyTtl: time.Minute,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	selectAll := func() []*dialer.Dialer {
+		var selected []*dialer.Dialer
+		for i := 0; i < 100; i++ {
+			d, _, err := g.SelectFor(TestNetworkType, true, &SelectOption{
+				Src: netip.AddrFrom4([4]byte{192, 168, 0, byte(i)}),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected = append(selected, d)
+		}
+		return selected
+	}
+	before := selectAll()
+	count := make(map[*dialer.Dialer]int)
+	for _, d := range before {
+		count[d]++
+	}
+	if len(count) < 2 {
+		t.Fatalf("expected keys to spread over dialers, got %v dialers", len(count))
+	}
+
+	// Only keys of the dead dialer move.
+	dead := before[0]
+	g.MustGetAliveDialerSet(TestNetworkType).NotifyLatencyChange(dead, false)
+	after := selectAll()
+	for i := range before {
+		if after[i] == dead {
+			t.Fatalf("key %v selected the dead dialer", i)
+		}
+		if before[i] != dead && after[i] != before[i] {
+			t.Errorf("key %v moved from an alive dialer", i)
+		}
+	}
+
+	// Keys stay after the dead dialer revives.
+	g.MustGetAliveDialerSet(TestNetworkType).NotifyLatencyChange(dead, true)
+	revived := selectAll()
+	for i := range after {
+		if revived[i] != after[i] {
+			t.Errorf("key %v moved after the dialer revived", i)
+		}
+	}
+}
+
+func TestDialerGroup_Select_Balance(t *testing.T) {
+
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	dialers := []*dialer.Dialer{
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+	}
+	g := NewDialerGroup(option, "test-group", dialers, []*dialer.Annotation{{}, {Weight: 3}, {}},
+		DialerSelectionPolicy{
+			Policy: consts.DialerSelectionPolicy_Balance,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	count := func() []int {
+		count := make([]int, len(dialers))
+		for i := 0; i < 5000; i++ {
+			d, _, err := g.Select(TestNetworkType, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j, dd := range dialers {
+				if d == dd {
+					count[j]++
+					break
+				}
+			}
+		}
+		t.Logf("count: %v", count)
+		return count
+	}
+	// Weight 1:3:1.
+	if c := count(); c[1] < 2*c[0] || c[1] < 2*c[2] {
+		t.Errorf("expected the dialer with weight 3 to be selected more")
+	}
+
+	// In flight connections lower the weight.
+	var releases []func()
+	for i := 0; i < 9; i++ {
+		releases = append(releases, dialers[1].AcquireInFlight())
+	}
+	if c := count(); c[1] > c[0] || c[1] > c[2] {
+		t.Errorf("expected the busy dialer to be selected less")
+	}
+	for _, release := range releases {
+		release()
+		release()
+	}
+	if dialers[1].InFlight() != 0 {
+		t.Errorf("expected no connection in flight, got %v", dialers[1].InFlight())
+	}
+}
+
+func TestDialerGroup_Select_Failover(t *testing.T) {
+
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	dialers := []*dialer.Dialer{
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+	}
+	// dialers[2] hits the first filter.
+	g := NewDialerGroup(option, "test-group", dialers, []*dialer.Annotation{{Priority: 1}, {Priority: 1}, {Priority: 0}},
+		DialerSelectionPolicy{
+			Policy:      consts.DialerSelectionPolicy_Failover,
+			RevertAfter: time.Hour,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	expect := func(expected *dialer.Dialer) {
+		t.Helper()
+		d, _, err := g.Select(TestNetworkType, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != expected {
+			t.Errorf("unexpected dialer selected")
+		}
+	}
+	a := g.MustGetAliveDialerSet(TestNetworkType)
+	expect(dialers[2])
+	a.NotifyLatencyChange(dialers[2], false)
+	expect(dialers[0])
+	a.NotifyLatencyChange(dialers[0], false)
+	expect(dialers[1])
+
+	// Hold down recovered dialers.
+	a.NotifyLatencyChange(dialers[0], true)
+	a.NotifyLatencyChange(dialers[2], true)
+	expect(dialers[1])
+	g.selectionPolicy.RevertAfter = 0
+	expect(dialers[2])
+}
+
+func TestDialerGroup_Select_Select(t *testing.T) {
+
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	var (
+		dialers     []*dialer.Dialer
+		annotations []*dialer.Annotation
+	)
+	for i := 0; i < 3; i++ {
+		d := newDirectDialer(option, false)
+		d.Property().Name = fmt.Sprintf("node%v", i)
+		dialers = append(dialers, d)
+		annotations = append(annotations, &dialer.Annotation{})
+	}
+	g := NewDialerGroup(option, "test-group", dialers, annotations,
+		DialerSelectionPolicy{
+			Policy:   consts.DialerSelectionPolicy_Select,
+			Fallback: &DialerSelectionPolicy{Policy: consts.DialerSelectionPolicy_Failover},
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	expect := func(expected *dialer.Dialer) {
+		t.Helper()
+		d, _, err := g.Select(TestNetworkType, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != expected {
+			t.Errorf("expected %v, got %v", expected.Property().Name, d.Property().Name)
+		}
+	}
+	// Not selected.
+	expect(dialers[0])
+
+	if err := g.SetSelected("node2"); err != nil {
+		t.Fatal(err)
+	}
+	expect(dialers[2])
+	if err := g.SetSelected("node3"); err == nil {
+		t.Errorf("expected error for a node not in the group")
+	}
+
+	// Fall back if the selected dialer dies.
+	g.MustGetAliveDialerSet(TestNetworkType).NotifyLatencyChange(dialers[2], false)
+	expect(dialers[0])
+	g.MustGetAliveDialerSet(TestNetworkType).NotifyLatencyChange(dialers[2], true)
+	expect(dialers[2])
+
+	if err := g.SetSelected(""); err != nil {
+		t.Fatal(err)
+	}
+	expect(dialers[0])
+}
+
+func TestDialerGroup_Select_MinAvg10WithLoss(t *testing.T) {
+
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	dialers := []*dialer.Dialer{
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+	}
+	g := NewDialerGroup(option, "test-group", dialers, []*dialer.Annotation{{}, {}},
+		DialerSelectionPolicy{
+			Policy:  consts.DialerSelectionPolicy_MinAverage10WithLoss,
+			MaxLoss: 0.2,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	a := g.MustGetAliveDialerSet(TestNetworkType)
+
+	// dialers[0] has one lucky fast probe and 50% loss.
+	dialers[0].MustGetProbes10(TestNetworkType).AppendSuccess(10 * time.Millisecond)
+	dialers[0].MustGetProbes10(TestNetworkType).AppendFailure()
+	a.NotifyLatencyChange(dialers[0], true)
+	for i := 0; i < 2; i++ {
+		dialers[1].MustGetProbes10(TestNetworkType).AppendSuccess(100 * time.Millisecond)
+	}
+	a.NotifyLatencyChange(dialers[1], true)
+
+	d, latency, err := g.Select(TestNetworkType, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != dialers[1] || latency != 100*time.Millisecond {
+		t.Errorf("expected the dialer without loss, got latency %v", latency)
+	}
+	if jitter, _ := dialers[1].MustGetProbes10(TestNetworkType).Jitter(); jitter != 0 {
+		t.Errorf("unexpected jitter: %v", jitter)
+	}
+
+	// dialers[0] recovers.
+	for i := 0; i < 8; i++ {
+		dialers[0].MustGetProbes10(TestNetworkType).AppendSuccess(10 * time.Millisecond)
+	}
+	a.NotifyLatencyChange(dialers[0], true)
+	if d, _, _ = g.Select(TestNetworkType, true); d != dialers[0] {
+		t.Errorf("expected the recovered dialer")
+	}
+}
+
+func TestParseRatio(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected float64
+		ok       bool
+	}{
+		{"20%", 0.2, true},
+		{"0.5", 0.5, true},
+		{"100%", 1, true},
+		{"120%", 0, false},
+		{"abc", 0, false},
+	} {
+		ratio, err := parseRatio(tc.in)
+		if (err == nil) != tc.ok || (tc.ok && math.Abs(ratio-tc.expected) > 1e-9) {
+			t.Errorf("parseRatio(%q) = %v, %v", tc.in, ratio, err)
+		}
+	}
+}
+
+func TestDialerGroup_PassiveHealth(t *testing.T) {
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	dialers := []*dialer.Dialer{
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+	}
+	g := NewDialerGroup(option, "test-group", dialers, []*dialer.Annotation{{}, {Priority: 1}},
+		DialerSelectionPolicy{
+			Policy: consts.DialerSelectionPolicy_Failover,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	expect := func(expected *dialer.Dialer) {
+		t.Helper()
+		d, _, err := g.Select(TestNetworkType, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != expected {
+			t.Errorf("unexpected dialer selected")
+		}
+	}
+	expect(dialers[0])
+	fail := func(i int) {
+		dialers[0].ReportDialFailure(TestNetworkType, fmt.Sprintf("192.0.2.%v:443", i), fmt.Errorf("test"))
+	}
+	for i := 0; i < dialer.PassiveFailureThreshold-1; i++ {
+		fail(i)
+	}
+	expect(dialers[0])
+	// A success resets consecutive failures.
+	dialers[0].ReportDialSuccess(TestNetworkType)
+	fail(0)
+	expect(dialers[0])
+	for i := 1; i < dialer.PassiveFailureThreshold; i++ {
+		fail(i)
+	}
+	expect(dialers[1])
+	if dialers[0].MustGetAlive(TestNetworkType) {
+		t.Errorf("expected the dialer not alive")
+	}
+
+	// Successful traffic closes the circuit.
+	dialers[0].ReportDialSuccess(TestNetworkType)
+	expect(dialers[0])
+}
+
+func TestDialerGroup_SelectAlternative(t *testing.T) {
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	dialers := []*dialer.Dialer{
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+		newDirectDialer(option, false),
+	}
+	g := NewDialerGroup(option, "test-group", dialers, []*dialer.Annotation{{}, {}, {}},
+		DialerSelectionPolicy{
+			Policy: consts.DialerSelectionPolicy_Failover,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	a := g.MustGetAliveDialerSet(TestNetworkType)
+	a.NotifyLatencyChange(dialers[1], false)
+	if d := g.SelectAlternative(TestNetworkType, dialers[:1]); d != dialers[2] {
+		t.Errorf("expected the only untried alive dialer")
+	}
+	if d := g.SelectAlternative(TestNetworkType, []*dialer.Dialer{dialers[0], dialers[2]}); d != nil {
+		t.Errorf("expected no alternative, got %v", d.Property().Name)
+	}
+
+	fixed := NewDialerGroup(option, "test-fixed", dialers, []*dialer.Annotation{{}, {}, {}},
+		DialerSelectionPolicy{
+			Policy:     consts.DialerSelectionPolicy_Fixed,
+			FixedIndex: 0,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	if d := fixed.SelectAlternative(TestNetworkType, dialers[:1]); d != nil {
+		t.Errorf("expected no alternative for policy fixed")
+	}
+}
+
+func TestDialerGroup_PassiveHealth_RefusedTarget(t *testing.T) {
+	direct.InitDirectDialers("127.0.0.1:53")
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	d := newDirectDialer(option, false)
+	g := NewDialerGroup(option, "test-group", []*dialer.Dialer{d}, []*dialer.Annotation{{}},
+		DialerSelectionPolicy{
+			Policy: consts.DialerSelectionPolicy_Failover,
+		}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	// Nothing listens on the port of the target, so the dial is refused by the target instead of the node.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := l.Addr().String()
+	_ = l.Close()
+	for i := 0; i < 2*dialer.PassiveFailureThreshold; i++ {
+		_, err := d.DialContext(context.TODO(), "tcp", target)
+		if err == nil {
+			t.Fatal("expected the dial to be refused")
+		}
+		d.ReportDialFailure(TestNetworkType, target, err)
+	}
+	if !d.MustGetAlive(TestNetworkType) {
+		t.Errorf("a refused target should not mark the node not alive")
+	}
+	if _, _, err = g.Select(TestNetworkType, true); err != nil {
+		t.Error(err)
+	}
+}
@@ -8,6 +8,8 @@ package outbound
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/daeuniverse/dae/common/consts"
 	"github.com/daeuniverse/dae/config"
@@ -16,6 +18,17 @@ import (
 type DialerSelectionPolicy struct {
 	Policy     consts.DialerSelectionPolicy
 	FixedIndex int
+
+	StickyKey consts.StickyKey
+	StickyTtl time.Duration
+
+	RevertAfter time.Duration
+
+	// MaxLoss is the max ratio of failed checks in range [0, 1] for policy min_avg10_with_loss.
+	MaxLoss float64
+
+	// Fallback is used by policy select if no dialer is selected or the selected one is not alive.
+	Fallback *DialerSelectionPolicy
 }
 
 func NewDialerSelectionPolicyFromGroupParam(param *config.Group) (policy *DialerSelectionPolicy, err error) {
@@ -28,7 +41,8 @@ func NewDialerSelectionPolicyFromGroupParam(param *config.Group) (policy *Dialer
 	case consts.DialerSelectionPolicy_Random,
 		consts.DialerSelectionPolicy_MinAverage10Latencies,
 		consts.DialerSelectionPolicy_MinLastLatency,
-		consts.DialerSelectionPolicy_MinMovingAverageLatencies:
+		consts.DialerSelectionPolicy_MinMovingAverageLatencies,
+		consts.DialerSelectionPolicy_Balance:
 		return &DialerSelectionPolicy{
 			Policy: fName,
 		}, nil
@@ -50,7 +64,123 @@ func NewDialerSelectionPolicyFromGroupParam(param *config.Group) (policy *Dialer
 			FixedIndex: index,
 		}, nil
 
+	case consts.DialerSelectionPolicy_Sticky:
+		if f.Not {
+			return nil, fmt.Errorf("policy param does not support not operator: !%v()", f.Name)
+		}
+		policy = &DialerSelectionPolicy{
+			Policy:    fName,
+			StickyKey: consts.StickyKey_SrcIp,
+			StickyTtl: consts.DefaultStickyTtl,
+		}
+		for _, param := range f.Params {
+			switch param.Key {
+			case "key":
+				switch key := consts.StickyKey(param.Val); key {
+				case consts.StickyKey_SrcIp, consts.StickyKey_Mac, consts.StickyKey_SrcIpDstDomain:
+					policy.StickyKey = key
+				default:
+					return nil, fmt.Errorf(`unexpected key of "%v": %v; available keys: src_ip, mac, src_ip+dst_domain`, f.Name, param.Val)
+				}
+			case "ttl":
+				if policy.StickyTtl, err = time.ParseDuration(param.Val); err != nil || policy.StickyTtl <= 0 {
+					return nil, fmt.Errorf(`invalid ttl of "%v": %v`, f.Name, param.Val)
+				}
+			default:
+				return nil, fmt.Errorf(`unknown param of "%v": %v`, f.Name, param.String(false, false))
+			}
+		}
+		return policy, nil
+
+	case consts.DialerSelectionPolicy_MinAverage10WithLoss:
+		if f.Not {
+			return nil, fmt.Errorf("policy param does not support not operator: !%v()", f.Name)
+		}
+		policy = &DialerSelectionPolicy{
+			Policy:  fName,
+			MaxLoss: consts.DefaultMaxLoss,
+		}
+		for _, param := range f.Params {
+			switch param.Key {
+			case "max_loss":
+				if policy.MaxLoss, err = parseRatio(param.Val); err != nil {
+					return nil, fmt.Errorf(`invalid max_loss of "%v": %w`, f.Name, err)
+				}
+			default:
+				return nil, fmt.Errorf(`unknown param of "%v": %v`, f.Name, param.String(false, false))
+			}
+		}
+		return policy, nil
+
+	case consts.DialerSelectionPolicy_Failover:
+		if f.Not {
+			return nil, fmt.Errorf("policy param does not support not operator: !%v()", f.Name)
+		}
+		policy = &DialerSelectionPolicy{
+			Policy: fName,
+		}
+		for _, param := range f.Params {
+			switch param.Key {
+			case "revert_after":
+				if policy.RevertAfter, err = time.ParseDuration(param.Val); err != nil || policy.RevertAfter < 0 {
+					return nil, fmt.Errorf(`invalid revert_after of "%v": %v`, f.Name, param.Val)
+				}
+			default:
+				return nil, fmt.Errorf(`unknown param of "%v": %v`, f.Name, param.String(false, false))
+			}
+		}
+		return policy, nil
+
+	case consts.DialerSelectionPolicy_Select:
+		if f.Not {
+			return nil, fmt.Errorf("policy param does not support not operator: !%v()", f.Name)
+		}
+		policy = &DialerSelectionPolicy{
+			Policy:   fName,
+			Fallback: &DialerSelectionPolicy{Policy: consts.DialerSelectionPolicy_MinMovingAverageLatencies},
+		}
+		for _, param := range f.Params {
+			switch param.Key {
+			case "fallback":
+				switch fallback := consts.DialerSelectionPolicy(param.Val); fallback {
+				case consts.DialerSelectionPolicy_Random,
+					consts.DialerSelectionPolicy_MinAverage10Latencies,
+					consts.DialerSelectionPolicy_MinLastLatency,
+					consts.DialerSelectionPolicy_MinMovingAverageLatencies,
+					consts.DialerSelectionPolicy_Balance,
+					consts.DialerSelectionPolicy_Failover:
+					policy.Fallback.Policy = fallback
+				default:
+					return nil, fmt.Errorf(`unsupported fallback of "%v": %v; available fallbacks: random, min, min_avg10, min_moving_avg, balance, failover`, f.Name, param.Val)
+				}
+			default:
+				return nil, fmt.Errorf(`unknown param of "%v": %v`, f.Name, param.String(false, false))
+			}
+		}
+		return policy, nil
+
 	default:
 		return nil, fmt.Errorf("unexpected policy: %v", f.Name)
 	}
 }
+
+// parseRatio parses a ratio like "20%" or "0.2" into range [0, 1].
+func parseRatio(s string) (float64, error) {
+	var (
+		ratio float64
+		err   error
+	)
+	if strings.HasSuffix(s, "%") {
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+		ratio /= 100
+	} else {
+		ratio, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("%v is out of range [0%%, 100%%]", s)
+	}
+	return ratio, nil
+}
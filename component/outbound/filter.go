@@ -7,9 +7,14 @@ package outbound
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
+	"github.com/daeuniverse/dae/common/assets"
+	"github.com/daeuniverse/dae/common/consts"
 	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/dae/config"
 	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/dlclark/regexp2"
 	"github.com/sirupsen/logrus"
@@ -19,6 +24,10 @@ const (
 	FilterInput_Name            = "name"
 	FilterInput_SubscriptionTag = "subtag"
 	FilterInput_Link            = "link"
+	FilterInput_Group           = "group"
+	FilterInput_Protocol        = "protocol"
+	FilterInput_Address         = "address"
+	FilterInput_Port            = "port"
 )
 
 const (
@@ -28,17 +37,83 @@ const (
 	FilterInput_SubscriptionTag_Regex = "regex"
 )
 
+// matchString matches the input with params of the filter. Keys regex and keyword are supported, and no key indicates
+// full match.
+func matchString(filter *config_parser.Function, input string) (hit bool, err error) {
+	for _, param := range filter.Params {
+		switch param.Key {
+		case FilterKey_Name_Regex:
+			regex, err := regexp2.Compile(param.Val, 0)
+			if err != nil {
+				return false, fmt.Errorf("bad regexp in filter %v: %w", filter.String(false, true, true), err)
+			}
+			if matched, _ := regex.MatchString(input); matched {
+				return true, nil
+			}
+		case FilterKey_Name_Keyword:
+			if strings.Contains(input, param.Val) {
+				return true, nil
+			}
+		case "":
+			if input == param.Val {
+				return true, nil
+			}
+		default:
+			return false, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, filter.Name)
+		}
+	}
+	return false, nil
+}
+
+// matchPort matches the port with params of the filter like "443" or "8000-9000".
+func matchPort(filter *config_parser.Function, port uint16) (hit bool, err error) {
+	for _, param := range filter.Params {
+		if param.Key != "" {
+			return false, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, filter.Name)
+		}
+		strFrom, strTo, isRange := strings.Cut(param.Val, "-")
+		from, err := strconv.ParseUint(strings.TrimSpace(strFrom), 10, 16)
+		if err != nil {
+			return false, fmt.Errorf("bad port in filter %v: %w", filter.String(false, true, true), err)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.ParseUint(strings.TrimSpace(strTo), 10, 16); err != nil {
+				return false, fmt.Errorf("bad port in filter %v: %w", filter.String(false, true, true), err)
+			}
+		}
+		if uint64(port) >= from && uint64(port) <= to {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 type DialerSet struct {
-	log          *logrus.Logger
-	dialers      []*dialer.Dialer
-	nodeToTagMap map[*dialer.Dialer]string
+	log                 *logrus.Logger
+	dialers             []*dialer.Dialer
+	nodeToTagMap        map[*dialer.Dialer]string
+	nodeToAnnotationMap map[*dialer.Dialer][]*config_parser.Param
+	// groupDialers are dialers of groups, which are only filtered by "group()".
+	groupDialers []*dialer.Dialer
+
+	locationFinder *assets.LocationFinder
+	geoIp          serverGeoIp
+	// resolverNetwork is the network to resolve server hosts for "server_geoip()", carrying the mark of dae.
+	resolverNetwork string
 }
 
-func NewDialerSetFromLinks(option *dialer.GlobalOption, tagToNodeList map[string][]string) *DialerSet {
+// NewDialerSetFromLinks creates dialers from node links. nodeAnnotations are node level annotations indexed by node
+// link, which can be nil. wans are direct dialers defined in section "wan" and merged into the set. locationFinder is used to find geoip.dat for "server_geoip()", which can be nil.
+// latencyState is the check history to restore, which can be nil.
+func NewDialerSetFromLinks(option *dialer.GlobalOption, tagToNodeList map[string][]string, nodeAnnotations map[string][]*config_parser.Param, wans []config.Wan, locationFinder *assets.LocationFinder, latencyState *LatencyState) *DialerSet {
 	s := &DialerSet{
-		log:          option.Log,
-		dialers:      make([]*dialer.Dialer, 0),
-		nodeToTagMap: make(map[*dialer.Dialer]string),
+		log:                 option.Log,
+		locationFinder:      locationFinder,
+		resolverNetwork:     option.TcpCheckOptionRaw.ResolverNetwork,
+		dialers:             make([]*dialer.Dialer, 0),
+		nodeToTagMap:        make(map[*dialer.Dialer]string),
+		nodeToAnnotationMap: make(map[*dialer.Dialer][]*config_parser.Param),
 	}
 	for subscriptionTag, nodes := range tagToNodeList {
 		for _, node := range nodes {
@@ -49,11 +124,56 @@ func NewDialerSetFromLinks(option *dialer.GlobalOption, tagToNodeList map[string
 			}
 			s.dialers = append(s.dialers, d)
 			s.nodeToTagMap[d] = subscriptionTag
+			if anno, ok := nodeAnnotations[node]; ok {
+				s.nodeToAnnotationMap[d] = anno
+			}
 		}
 	}
+	for i := range wans {
+		d, p, err := dialer.NewWanDialer(&wans[i])
+		if err != nil {
+			s.log.Warnf("failed to create wan: %v", err)
+			continue
+		}
+		s.dialers = append(s.dialers, dialer.NewDialer(d, option, dialer.InstanceOption{DisableCheck: false}, p))
+	}
+	s.restoreLatencyState(latencyState)
 	return s
 }
 
+// AddGroupDialer adds the dialer of a group created by NewGroupDialer for groups created later to filter.
+func (s *DialerSet) AddGroupDialer(d *dialer.Dialer) {
+	s.groupDialers = append(s.groupDialers, d)
+}
+
+// FilterGroupReferences returns names of groups referenced by "group()" in filters.
+func FilterGroupReferences(filters [][]*config_parser.Function) (names []string, err error) {
+	for _, andFunctions := range filters {
+		for _, f := range andFunctions {
+			if f.Name != FilterInput_Group {
+				continue
+			}
+			for _, param := range f.Params {
+				if param.Key != "" {
+					return nil, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, f.Name)
+				}
+				names = append(names, param.Val)
+			}
+		}
+	}
+	return names, nil
+}
+
+// refGroup returns true if the filter hits groups, that is, it has a "group()" without not operator.
+func refGroup(filter []*config_parser.Function) bool {
+	for _, f := range filter {
+		if f.Name == FilterInput_Group && !f.Not {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *DialerSet) filterHit(dialer *dialer.Dialer, filters []*config_parser.Function) (hit bool, err error) {
 	if len(filters) == 0 {
 		// No filter.
@@ -127,6 +247,69 @@ func (s *DialerSet) filterHit(dialer *dialer.Dialer, filters []*config_parser.Fu
 				}
 			}
 
+		case FilterInput_Link:
+			if subFilterHit, err = matchString(filter, dialer.Property().Link); err != nil {
+				return false, err
+			}
+
+		case FilterInput_Address:
+			host, _, e := net.SplitHostPort(dialer.Property().Address)
+			if e != nil {
+				host = dialer.Property().Address
+			}
+			if subFilterHit, err = matchString(filter, host); err != nil {
+				return false, err
+			}
+
+		case FilterInput_Protocol:
+			// Or
+			for _, param := range filter.Params {
+				if param.Key != "" {
+					return false, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, filter.Name)
+				}
+				if consts.CanonicalProtocol(param.Val) == consts.CanonicalProtocol(dialer.Property().Protocol) {
+					subFilterHit = true
+					break
+				}
+			}
+
+		case FilterInput_Port:
+			_, strPort, e := net.SplitHostPort(dialer.Property().Address)
+			port, e2 := strconv.ParseUint(strPort, 10, 16)
+			if e != nil || e2 != nil {
+				// No port, such as groups.
+				if _, err = matchPort(filter, 0); err != nil {
+					return false, err
+				}
+				break
+			}
+			if subFilterHit, err = matchPort(filter, uint16(port)); err != nil {
+				return false, err
+			}
+
+		case FilterInput_ServerGeoIp:
+			if dialer.Property().Protocol == GroupDialerProtocol {
+				break
+			}
+			if subFilterHit, err = s.matchServerGeoIp(dialer, filter); err != nil {
+				return false, err
+			}
+
+		case FilterInput_Group:
+			if dialer.Property().Protocol != GroupDialerProtocol {
+				break
+			}
+			// Or
+			for _, param := range filter.Params {
+				if param.Key != "" {
+					return false, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, filter.Name)
+				}
+				if dialer.Property().Name == param.Val {
+					subFilterHit = true
+					break
+				}
+			}
+
 		default:
 			return false, fmt.Errorf(`unsupported filter input type: "%v"`, filter.Name)
 		}
@@ -144,8 +327,10 @@ func (s *DialerSet) FilterAndAnnotate(filters [][]*config_parser.Function, annot
 	}
 	if len(filters) == 0 {
 		anno := make([]*dialer.Annotation, len(s.dialers))
-		for i := range anno {
-			anno[i] = &dialer.Annotation{}
+		for i, d := range s.dialers {
+			if anno[i], err = dialer.NewAnnotation(s.nodeToAnnotationMap[d]); err != nil {
+				return nil, nil, fmt.Errorf("apply node annotation: %w", err)
+			}
 		}
 		return s.dialers, anno, nil
 	}
@@ -158,16 +343,41 @@ nextDialerLoop:
 				return nil, nil, err
 			}
 			if hit {
-				anno, err := dialer.NewAnnotation(annotations[j])
+				// Filter annotations take precedence over node annotations.
+				params := append(append([]*config_parser.Param{}, annotations[j]...), s.nodeToAnnotationMap[d]...)
+				anno, err := dialer.NewAnnotation(params)
 				if err != nil {
 					return nil, nil, fmt.Errorf("apply filter annotation: %w", err)
 				}
+				anno.Priority = j
 				dialers = append(dialers, d)
 				filterAnnotations = append(filterAnnotations, anno)
 				continue nextDialerLoop
 			}
 		}
 	}
+nextGroupDialerLoop:
+	for _, d := range s.groupDialers {
+		for j, f := range filters {
+			if !refGroup(f) {
+				continue
+			}
+			hit, err := s.filterHit(d, f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if hit {
+				anno, err := dialer.NewAnnotation(annotations[j])
+				if err != nil {
+					return nil, nil, fmt.Errorf("apply filter annotation: %w", err)
+				}
+				anno.Priority = j
+				dialers = append(dialers, d)
+				filterAnnotations = append(filterAnnotations, anno)
+				continue nextGroupDialerLoop
+			}
+		}
+	}
 	return dialers, filterAnnotations, nil
 }
 
@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae/common/netutils"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/daeuniverse/dae/pkg/geodata"
+	"github.com/daeuniverse/outbound/protocol/direct"
+)
+
+const (
+	FilterInput_ServerGeoIp = "server_geoip"
+
+	serverResolveTimeout = 5 * time.Second
+)
+
+// serverGeoIp resolves server hosts of dialers and matches them with geoip.dat. Server hosts are resolved once per
+// DialerSet, which is rebuilt on every reload and subscription refresh.
+type serverGeoIp struct {
+	resolveOnce sync.Once
+	serverIps   map[*dialer.Dialer][]netip.Addr
+
+	mu    sync.Mutex
+	cidrs map[string][]netip.Prefix
+}
+
+func (s *DialerSet) resolveServerIps() {
+	s.geoIp.serverIps = make(map[*dialer.Dialer][]netip.Addr, len(s.dialers))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, d := range s.dialers {
+		host, _, err := net.SplitHostPort(d.Property().Address)
+		if err != nil {
+			host = d.Property().Address
+		}
+		if ip, err := netip.ParseAddr(host); err == nil {
+			s.geoIp.serverIps[d] = []netip.Addr{ip.Unmap()}
+			continue
+		}
+		wg.Add(1)
+		go func(d *dialer.Dialer, host string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), serverResolveTimeout)
+			defer cancel()
+			systemDns, err := netutils.SystemDns()
+			if err != nil {
+				s.log.Warnf("failed to resolve server of node %v: %v", d.Property().Name, err)
+				return
+			}
+			ip46, err4, err6 := netutils.ResolveIp46(ctx, direct.SymmetricDirect, systemDns, host, s.resolverNetwork, false)
+			var ips []netip.Addr
+			if ip46.Ip4.IsValid() {
+				ips = append(ips, ip46.Ip4.Unmap())
+			}
+			if ip46.Ip6.IsValid() {
+				ips = append(ips, ip46.Ip6)
+			}
+			if len(ips) == 0 {
+				s.log.Warnf("failed to resolve server of node %v: %v", d.Property().Name, errors.Join(err4, err6))
+				return
+			}
+			mu.Lock()
+			s.geoIp.serverIps[d] = ips
+			mu.Unlock()
+		}(d, host)
+	}
+	wg.Wait()
+}
+
+func (s *DialerSet) geoIpCidrs(code string) ([]netip.Prefix, error) {
+	code = strings.ToLower(code)
+	s.geoIp.mu.Lock()
+	defer s.geoIp.mu.Unlock()
+	if cidrs, ok := s.geoIp.cidrs[code]; ok {
+		return cidrs, nil
+	}
+	if s.locationFinder == nil {
+		return nil, fmt.Errorf("geoip.dat is not available")
+	}
+	filePath, err := s.locationFinder.GetLocationAsset(s.log, "geoip.dat")
+	if err != nil {
+		return nil, err
+	}
+	geoIp, err := geodata.UnmarshalGeoIp(s.log, filePath, code)
+	if err != nil {
+		return nil, err
+	}
+	if geoIp.InverseMatch {
+		return nil, fmt.Errorf("not support inverse match yet")
+	}
+	cidrs := make([]netip.Prefix, 0, len(geoIp.Cidr))
+	for _, item := range geoIp.Cidr {
+		ip, ok := netip.AddrFromSlice(item.Ip)
+		if !ok {
+			return nil, fmt.Errorf("bad geoip file: %v", filePath)
+		}
+		cidrs = append(cidrs, netip.PrefixFrom(ip.Unmap(), int(item.Prefix)-(ip.BitLen()-ip.Unmap().BitLen())))
+	}
+	if s.geoIp.cidrs == nil {
+		s.geoIp.cidrs = make(map[string][]netip.Prefix)
+	}
+	s.geoIp.cidrs[code] = cidrs
+	return cidrs, nil
+}
+
+// matchServerGeoIp reports whether any resolved server ip of the dialer is in geoip of any code in the filter.
+func (s *DialerSet) matchServerGeoIp(d *dialer.Dialer, filter *config_parser.Function) (hit bool, err error) {
+	s.geoIp.resolveOnce.Do(s.resolveServerIps)
+	ips := s.geoIp.serverIps[d]
+	for _, param := range filter.Params {
+		if param.Key != "" {
+			return false, fmt.Errorf(`unsupported filter key "%v" in "filter: %v()"`, param.Key, filter.Name)
+		}
+		cidrs, err := s.geoIpCidrs(param.Val)
+		if err != nil {
+			return false, fmt.Errorf("failed to load geoip %v: %w", param.Val, err)
+		}
+		for _, ip := range ips {
+			for _, cidr := range cidrs {
+				if cidr.Contains(ip) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,134 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/dae/config"
+	D "github.com/daeuniverse/outbound/dialer"
+	"github.com/daeuniverse/outbound/netproxy"
+)
+
+const GroupDialerProtocol = "group"
+
+// groupDialer dials through the dialer currently selected by the group, which makes the group a member of other
+// groups.
+type groupDialer struct {
+	group *DialerGroup
+}
+
+func (d *groupDialer) DialContext(ctx context.Context, network, addr string) (netproxy.Conn, error) {
+	magicNetwork, err := netproxy.ParseMagicNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	networkType := &dialer.NetworkType{
+		L4Proto:   consts.L4ProtoStr(magicNetwork.Network),
+		IpVersion: consts.IpVersionStr_4,
+		IsDns:     false,
+	}
+	strictIpVersion := false
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip, err := netip.ParseAddr(host); err == nil {
+			networkType.IpVersion = consts.IpVersionFromAddr(ip)
+			strictIpVersion = true
+		}
+	}
+	sel, _, err := d.group.Select(networkType, strictIpVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select dialer from group %v (%v): %w", d.group.Name, networkType.String(), err)
+	}
+	return sel.DialContext(ctx, network, addr)
+}
+
+// NewGroupDialer creates a dialer from the group to be filtered by "group()" of other groups. Its latency and alive
+// state are checked through the current selection of the group.
+func NewGroupDialer(option *dialer.GlobalOption, g *DialerGroup) *dialer.Dialer {
+	return dialer.NewDialer(&groupDialer{group: g}, option, dialer.InstanceOption{DisableCheck: false}, &dialer.Property{
+		Property: D.Property{
+			Name:     g.Name,
+			Protocol: GroupDialerProtocol,
+			Link:     GroupDialerProtocol + "://" + g.Name,
+		},
+	})
+}
+
+// NewViaDialer creates a netproxy dialer that dials through the current selection of the group, which is used to chain
+// nodes of other groups by "dialer_via".
+func NewViaDialer(g *DialerGroup) netproxy.Dialer {
+	return &groupDialer{group: g}
+}
+
+// SortGroupsByReference returns indexes of groups in an order that a group is after groups it references by
+// "group()" or "dialer_via", and whether each group is referenced by others by "group()".
+func SortGroupsByReference(groups []config.Group) (order []int, referenced []bool, err error) {
+	nameToIndex := make(map[string]int, len(groups))
+	for i, g := range groups {
+		nameToIndex[g.Name] = i
+	}
+	refs := make([][]int, len(groups))
+	referenced = make([]bool, len(groups))
+	for i, g := range groups {
+		names, err := FilterGroupReferences(g.Filter)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`group "%v": %w`, g.Name, err)
+		}
+		for _, name := range names {
+			j, ok := nameToIndex[name]
+			if !ok {
+				return nil, nil, fmt.Errorf(`group "%v" references group "%v" which does not exist`, g.Name, name)
+			}
+			refs[i] = append(refs[i], j)
+			referenced[j] = true
+		}
+		if g.DialerVia != "" {
+			j, ok := nameToIndex[g.DialerVia]
+			if !ok {
+				return nil, nil, fmt.Errorf(`group "%v" is dialed via group "%v" which does not exist`, g.Name, g.DialerVia)
+			}
+			refs[i] = append(refs[i], j)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(groups))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		path = append(path, groups[i].Name)
+		switch states[i] {
+		case visiting:
+			return fmt.Errorf("circular group reference: %v", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		states[i] = visiting
+		for _, j := range refs[i] {
+			if err := visit(j, path); err != nil {
+				return err
+			}
+		}
+		states[i] = visited
+		order = append(order, i)
+		return nil
+	}
+	for i := range groups {
+		if err = visit(i, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return order, referenced, nil
+}
@@ -0,0 +1,146 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/daeuniverse/outbound/netproxy"
+)
+
+func groupFilter(names ...string) [][]*config_parser.Function {
+	f := &config_parser.Function{Name: FilterInput_Group}
+	for _, name := range names {
+		f.Params = append(f.Params, &config_parser.Param{Val: name})
+	}
+	return [][]*config_parser.Function{{f}}
+}
+
+func TestSortGroupsByReference(t *testing.T) {
+	groups := []config.Group{
+		{Name: "best", Filter: groupFilter("hk", "jp")},
+		{Name: "hk"},
+		{Name: "jp", Filter: groupFilter("hk")},
+	}
+	order, referenced, err := SortGroupsByReference(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 0 {
+		t.Errorf("unexpected order: %v", order)
+	}
+	if referenced[0] || !referenced[1] || !referenced[2] {
+		t.Errorf("unexpected referenced: %v", referenced)
+	}
+
+	groups[1].Filter = groupFilter("best")
+	if _, _, err = SortGroupsByReference(groups); err == nil {
+		t.Errorf("expected error for circular reference")
+	}
+	groups[1].Filter = groupFilter("unknown")
+	if _, _, err = SortGroupsByReference(groups); err == nil {
+		t.Errorf("expected error for unknown group")
+	}
+}
+
+func TestDialerSet_FilterGroup(t *testing.T) {
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	s := NewDialerSetFromLinks(option, map[string][]string{"sub": {"socks5://1.2.3.4:1080#node"}}, nil, nil, nil, nil)
+	inner := NewDialerGroup(option, "inner", s.dialers, []*dialer.Annotation{{}}, DialerSelectionPolicy{
+		Policy: consts.DialerSelectionPolicy_Random,
+	}, func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	s.AddGroupDialer(NewGroupDialer(option, inner))
+
+	// Groups are only hit by group().
+	dialers, _, err := s.FilterAndAnnotate(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dialers) != 1 || dialers[0].Property().Name != "node" {
+		t.Errorf("unexpected dialers: %v", len(dialers))
+	}
+	dialers, _, err = s.FilterAndAnnotate(append(groupFilter("inner"), []*config_parser.Function{{Name: FilterInput_Name, Not: true, Params: []*config_parser.Param{{Val: "x"}}}}), [][]*config_parser.Param{nil, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dialers) != 2 || dialers[0].Property().Name != "node" || dialers[1].Property().Name != "inner" {
+		t.Errorf("unexpected dialers: %v", len(dialers))
+	}
+}
+
+func TestSortGroupsByReference_DialerVia(t *testing.T) {
+	groups := []config.Group{
+		{Name: "landing", DialerVia: "relay"},
+		{Name: "relay"},
+	}
+	order, referenced, err := SortGroupsByReference(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 0 {
+		t.Errorf("unexpected order: %v", order)
+	}
+	// dialer_via does not make the group a member of others.
+	if referenced[0] || referenced[1] {
+		t.Errorf("unexpected referenced: %v", referenced)
+	}
+
+	groups[1].DialerVia = "landing"
+	if _, _, err = SortGroupsByReference(groups); err == nil {
+		t.Errorf("expected error for circular reference")
+	}
+	groups[1].DialerVia = "unknown"
+	if _, _, err = SortGroupsByReference(groups); err == nil {
+		t.Errorf("expected error for unknown group")
+	}
+}
+
+type recordDialer struct {
+	addrs []string
+}
+
+func (d *recordDialer) DialContext(ctx context.Context, network, addr string) (netproxy.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	return nil, fmt.Errorf("refused")
+}
+
+func TestDialer_CloneVia(t *testing.T) {
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	s := NewDialerSetFromLinks(option, map[string][]string{"sub": {"socks5://1.2.3.4:1080#landing"}}, nil, nil, nil, nil)
+	defer s.Close()
+	via := &recordDialer{}
+	d, err := s.dialers[0].CloneVia(via)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Property().Name != "landing" || d.Property().SubscriptionTag != "sub" {
+		t.Errorf("unexpected property: %v", d.Property())
+	}
+	if _, err = d.DialContext(context.TODO(), "tcp", "example.com:80"); err == nil {
+		t.Errorf("expected error")
+	}
+	if len(via.addrs) != 1 || via.addrs[0] != "1.2.3.4:1080" {
+		t.Errorf("expected to dial the node via the relay, got %v", via.addrs)
+	}
+}
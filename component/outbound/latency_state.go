@@ -0,0 +1,99 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+)
+
+const (
+	// LatencyStateFileName is the name of file in the config directory that persists check history of nodes.
+	LatencyStateFileName = "latency_state.json"
+	// MaxLatencyStateAge is the max age of the latency state to restore. Older history is meaningless.
+	MaxLatencyStateAge = time.Hour
+)
+
+// LatencyState is the check history of nodes indexed by hashes of node links.
+type LatencyState struct {
+	UpdatedAt time.Time               `json:"updated_at"`
+	Nodes     map[string]dialer.State `json:"nodes"`
+}
+
+// LinkHash hashes the node link, which avoids persisting credentials in links.
+func LinkHash(link string) string {
+	h := sha256.Sum256([]byte(link))
+	return hex.EncodeToString(h[:16])
+}
+
+// ReadLatencyState reads the latency state file. It returns nil if the file does not exist or is too old.
+func ReadLatencyState(path string) (*LatencyState, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state LatencyState
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("bad latency state file %v: %w", path, err)
+	}
+	if time.Since(state.UpdatedAt) > MaxLatencyStateAge {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// WriteLatencyState writes the latency state file atomically.
+func WriteLatencyState(path string, state *LatencyState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LatencyState returns the check history of nodes in the set.
+func (s *DialerSet) LatencyState() *LatencyState {
+	state := &LatencyState{
+		UpdatedAt: time.Now(),
+		Nodes:     make(map[string]dialer.State, len(s.dialers)),
+	}
+	for _, d := range s.dialers {
+		if ds := d.State(); len(ds) > 0 {
+			state.Nodes[LinkHash(d.Property().Link)] = ds
+		}
+	}
+	return state
+}
+
+func (s *DialerSet) restoreLatencyState(state *LatencyState) {
+	if state == nil {
+		return
+	}
+	var restored int
+	for _, d := range s.dialers {
+		if ds, ok := state.Nodes[LinkHash(d.Property().Link)]; ok {
+			d.RestoreState(ds)
+			restored++
+		}
+	}
+	if restored > 0 {
+		s.log.Infof("Restored check history of %v nodes", restored)
+	}
+}
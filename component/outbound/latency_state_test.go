@@ -0,0 +1,77 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+)
+
+func TestLatencyState(t *testing.T) {
+	option := &dialer.GlobalOption{
+		Log:               log,
+		TcpCheckOptionRaw: dialer.TcpCheckOptionRaw{Raw: []string{testTcpCheckUrl}},
+		CheckDnsOptionRaw: dialer.CheckDnsOptionRaw{Raw: []string{testUdpCheckDns}},
+		CheckInterval:     15 * time.Second,
+	}
+	links := map[string][]string{"sub": {
+		"socks5://1.2.3.4:1080#alive",
+		"socks5://5.6.7.8:1080#dead",
+	}}
+	s := NewDialerSetFromLinks(option, links, nil, nil, nil, nil)
+	defer s.Close()
+	for _, d := range s.dialers {
+		switch d.Property().Name {
+		case "alive":
+			d.MustGetLatencies10(TestNetworkType).AppendLatency(100 * time.Millisecond)
+			d.MustGetLatencies10(TestNetworkType).AppendLatency(200 * time.Millisecond)
+		case "dead":
+			d.ReportUnavailable(TestNetworkType, nil)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), LatencyStateFileName)
+	if err := WriteLatencyState(path, s.LatencyState()); err != nil {
+		t.Fatal(err)
+	}
+	state, err := ReadLatencyState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewDialerSetFromLinks(option, links, nil, nil, nil, state)
+	defer restored.Close()
+	for _, d := range restored.dialers {
+		switch d.Property().Name {
+		case "alive":
+			if avg, _ := d.MustGetLatencies10(TestNetworkType).AvgLatency(); avg != 150*time.Millisecond {
+				t.Errorf("unexpected restored avg_10: %v", avg)
+			}
+			if !d.MustGetAlive(TestNetworkType) {
+				t.Errorf("expected restored alive")
+			}
+		case "dead":
+			if d.MustGetAlive(TestNetworkType) {
+				t.Errorf("expected restored not alive")
+			}
+			if loss, _ := d.MustGetProbes10(TestNetworkType).Loss(); loss != 1 {
+				t.Errorf("unexpected restored loss: %v", loss)
+			}
+		}
+	}
+
+	// Too old state is ignored.
+	state.UpdatedAt = time.Now().Add(-2 * MaxLatencyStateAge)
+	if err = WriteLatencyState(path, state); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = ReadLatencyState(path); err != nil || state != nil {
+		t.Errorf("expected no state, got %v, %v", state, err)
+	}
+}
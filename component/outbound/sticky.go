@@ -0,0 +1,100 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package outbound
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+)
+
+// SelectOption describes the connection to select a dialer for. It is used by policies depending on the client, and
+// can be nil if the connection is not from a client.
+type SelectOption struct {
+	Src    netip.Addr
+	Mac    [6]uint8
+	Dst    netip.Addr
+	Domain string
+}
+
+func (o *SelectOption) stickyKey(key consts.StickyKey) string {
+	if o == nil {
+		return ""
+	}
+	switch key {
+	case consts.StickyKey_Mac:
+		if o.Mac != [6]uint8{} {
+			return net.HardwareAddr(o.Mac[:]).String()
+		}
+		// Local traffic has no mac address.
+		return o.Src.String()
+	case consts.StickyKey_SrcIpDstDomain:
+		if o.Domain != "" {
+			return o.Src.String() + "+" + o.Domain
+		}
+		return o.Src.String() + "+" + o.Dst.String()
+	default:
+		return o.Src.String()
+	}
+}
+
+type stickyTableKey struct {
+	aliveDialerSet *dialer.AliveDialerSet
+	key            string
+}
+
+type stickyEntry struct {
+	dialer   *dialer.Dialer
+	expireAt time.Time
+}
+
+// stickyTable keeps the dialer selected for each key until the dialer dies or the key is idle for ttl.
+type stickyTable struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	m         map[stickyTableKey]*stickyEntry
+	nextSweep time.Time
+}
+
+func newStickyTable(ttl time.Duration) *stickyTable {
+	return &stickyTable{
+		ttl:       ttl,
+		m:         make(map[stickyTableKey]*stickyEntry),
+		nextSweep: time.Now().Add(ttl),
+	}
+}
+
+func (t *stickyTable) Get(a *dialer.AliveDialerSet, key string) *dialer.Dialer {
+	now := time.Now()
+	k := stickyTableKey{aliveDialerSet: a, key: key}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.After(t.nextSweep) {
+		for k, e := range t.m {
+			if now.After(e.expireAt) {
+				delete(t.m, k)
+			}
+		}
+		t.nextSweep = now.Add(t.ttl)
+	}
+	if e, ok := t.m[k]; ok && now.Before(e.expireAt) && a.IsAlive(e.dialer) {
+		e.expireAt = now.Add(t.ttl)
+		return e.dialer
+	}
+	d := a.GetConsistent(key)
+	if d == nil {
+		delete(t.m, k)
+		return nil
+	}
+	t.m[k] = &stickyEntry{dialer: d, expireAt: now.Add(t.ttl)}
+	return d
+}
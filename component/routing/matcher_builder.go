@@ -10,6 +10,7 @@ import (
 	"github.com/daeuniverse/dae/common/consts"
 	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/sirupsen/logrus"
+	"net"
 	"strconv"
 )
 
@@ -23,6 +24,16 @@ type Outbound struct {
 	Name string
 	Mark uint32
 	Must bool
+	// UdpNatMode overrides the global udp_nat_mode for traffic hitting the rule.
+	UdpNatMode consts.UdpNatMode
+	// Redirect is not nil for outbound redirect.
+	Redirect *Redirect
+}
+
+// Redirect rewrites the destination of traffic to Target.
+type Redirect struct {
+	Target        string
+	ProxyProtocol bool
 }
 
 type RulesBuilder struct {
@@ -116,9 +127,35 @@ func ParseOutbound(rawOutbound *config_parser.Function) (outbound *Outbound, err
 				return nil, fmt.Errorf("failed to parse mark: %v", err)
 			}
 			outbound.Mark = uint32(_mark)
+		case consts.OutboundParam_UdpNatMode:
+			if outbound.UdpNatMode, err = consts.ParseUdpNatMode(p.Val); err != nil {
+				return nil, err
+			}
+		case consts.OutboundParam_ProxyProtocol:
+			if rawOutbound.Name != consts.OutboundRedirect {
+				return nil, fmt.Errorf("unknown outbound param key: %v", p.Key)
+			}
+			if p.Val != consts.ProxyProtocol_V2 {
+				return nil, fmt.Errorf("unsupported proxy protocol version: %v", p.Val)
+			}
+			if outbound.Redirect == nil {
+				outbound.Redirect = &Redirect{}
+			}
+			outbound.Redirect.ProxyProtocol = true
 		case "":
 			if p.Val == "must" {
 				outbound.Must = true
+			} else if rawOutbound.Name == consts.OutboundRedirect && (outbound.Redirect == nil || outbound.Redirect.Target == "") {
+				if _, _, err = net.SplitHostPort(p.Val); err != nil {
+					return nil, fmt.Errorf("bad redirect target: %w", err)
+				}
+				if outbound.Redirect == nil {
+					outbound.Redirect = &Redirect{}
+				}
+				outbound.Redirect.Target = p.Val
+			} else if rawOutbound.Name == consts.OutboundReject && (p.Val == consts.RejectWith_TcpReset || p.Val == consts.RejectWith_IcmpUnreached) {
+				// Outbounds are identified by names, so "reject(icmp)" is the name of the built-in outbound.
+				outbound.Name = consts.OutboundReject + "(" + p.Val + ")"
 			} else {
 				return nil, fmt.Errorf("unknown outbound param: %v", p.Val)
 			}
@@ -126,5 +163,19 @@ func ParseOutbound(rawOutbound *config_parser.Function) (outbound *Outbound, err
 			return nil, fmt.Errorf("unknown outbound param key: %v", p.Key)
 		}
 	}
+	if rawOutbound.Name == consts.OutboundRedirect {
+		if outbound.Redirect == nil || outbound.Redirect.Target == "" {
+			return nil, fmt.Errorf("redirect target is required, e.g. redirect('127.0.0.1:8080')")
+		}
+		// Every redirect target is a built-in outbound created on demand, and is named by its target and options.
+		outbound.Name = outbound.Redirect.String()
+	}
 	return outbound, nil
 }
+
+func (r *Redirect) String() string {
+	if r.ProxyProtocol {
+		return consts.OutboundRedirect + "(" + r.Target + ", " + consts.OutboundParam_ProxyProtocol + ": " + consts.ProxyProtocol_V2 + ")"
+	}
+	return consts.OutboundRedirect + "(" + r.Target + ")"
+}
@@ -29,10 +29,12 @@ type Global struct {
 	UdpCheckDns                []string      `mapstructure:"udp_check_dns" default:"dns.google:53,8.8.8.8,2001:4860:4860::8888"`
 	CheckInterval              time.Duration `mapstructure:"check_interval" default:"30s"`
 	CheckTolerance             time.Duration `mapstructure:"check_tolerance" default:"0"`
+	CheckConcurrency           uint16        `mapstructure:"check_concurrency" default:"16"`
 	LanInterface               []string      `mapstructure:"lan_interface"`
 	WanInterface               []string      `mapstructure:"wan_interface"`
 	AllowInsecure              bool          `mapstructure:"allow_insecure" default:"false"`
 	DialMode                   string        `mapstructure:"dial_mode" default:"domain"`
+	UdpNatMode                 string        `mapstructure:"udp_nat_mode" default:"full_cone"`
 	DisableWaitingNetwork      bool          `mapstructure:"disable_waiting_network" default:"false"`
 	EnableLocalTcpFastRedirect bool          `mapstructure:"enable_local_tcp_fast_redirect" default:"false"`
 	AutoConfigKernelParameter  bool          `mapstructure:"auto_config_kernel_parameter" default:"false"`
@@ -50,6 +52,9 @@ type Global struct {
 	BandwidthMaxTx         string        `mapstructure:"bandwidth_max_tx" default:"0"`
 	BandwidthMaxRx         string        `mapstructure:"bandwidth_max_rx" default:"0"`
 	UDPHopInterval         time.Duration `mapstructure:"udphop_interval" default:"30s"`
+	// Warn if remaining traffic of a subscription is less than this percent of total.
+	SubscriptionTrafficWarning uint8         `mapstructure:"subscription_traffic_warning" default:"10"`
+	SubscriptionExpireWarning  time.Duration `mapstructure:"subscription_expire_warning" default:"72h"`
 }
 
 type Utls struct {
@@ -96,12 +101,53 @@ type Group struct {
 	Filter           [][]*config_parser.Function `mapstructure:"filter" repeatable:""`
 	FilterAnnotation [][]*config_parser.Param    `mapstructure:"_"`
 	Policy           FunctionListOrString        `mapstructure:"policy" required:""`
+	DialerVia        string                      `mapstructure:"dialer_via"`
+	OnFail           string                      `mapstructure:"on_fail"`
 
 	TcpCheckUrl        []string      `mapstructure:"tcp_check_url"`
 	TcpCheckHttpMethod string        `mapstructure:"tcp_check_http_method"`
 	UdpCheckDns        []string      `mapstructure:"udp_check_dns"`
 	CheckInterval      time.Duration `mapstructure:"check_interval"`
 	CheckTolerance     time.Duration `mapstructure:"check_tolerance"`
+
+	// Options of nodes. Pointers indicate whether they are set to override global ones.
+	AllowInsecure       *bool         `mapstructure:"allow_insecure"`
+	TlsImplementation   string        `mapstructure:"tls_implementation"`
+	UtlsImitate         string        `mapstructure:"utls_imitate"`
+	TlsFragment         *bool         `mapstructure:"tls_fragment"`
+	TlsFragmentLength   string        `mapstructure:"tls_fragment_length"`
+	TlsFragmentInterval string        `mapstructure:"tls_fragment_interval"`
+	BandwidthMaxTx      string        `mapstructure:"bandwidth_max_tx"`
+	BandwidthMaxRx      string        `mapstructure:"bandwidth_max_rx"`
+	UDPHopInterval      time.Duration `mapstructure:"udphop_interval"`
+	Mptcp               *bool         `mapstructure:"mptcp"`
+}
+
+type Wan struct {
+	Name string `mapstructure:"_"`
+
+	Iface string `mapstructure:"iface"`
+	Mark  uint32 `mapstructure:"mark"`
+}
+
+type Inbound struct {
+	Name string `mapstructure:"_"`
+
+	Listen   string `mapstructure:"listen" required:""`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+type SubscriptionOption struct {
+	Name string `mapstructure:"_"`
+
+	Via       string                      `mapstructure:"via"`
+	Timeout   time.Duration               `mapstructure:"timeout" default:"30s"`
+	Retry     uint8                       `mapstructure:"retry" default:"2"`
+	Drop      [][]*config_parser.Function `mapstructure:"drop" repeatable:""`
+	Rename    [][]*config_parser.Function `mapstructure:"rename" repeatable:""`
+	Dedupe    bool                        `mapstructure:"dedupe" default:"false"`
+	TagPrefix bool                        `mapstructure:"tag_prefix" default:"false"`
 }
 
 type DnsRequestRouting struct {
@@ -130,12 +176,15 @@ type Routing struct {
 }
 
 type Config struct {
-	Global       Global          `mapstructure:"global" required:"" desc:"GlobalDesc"`
-	Subscription []KeyableString `mapstructure:"subscription"`
-	Node         []KeyableString `mapstructure:"node"`
-	Group        []Group         `mapstructure:"group" desc:"GroupDesc"`
-	Routing      Routing         `mapstructure:"routing" required:""`
-	Dns          Dns             `mapstructure:"dns" desc:"DnsDesc"`
+	Global             Global               `mapstructure:"global" required:"" desc:"GlobalDesc"`
+	Subscription       []KeyableString      `mapstructure:"subscription"`
+	SubscriptionOption []SubscriptionOption `mapstructure:"subscription_option" desc:"SubscriptionOptionDesc"`
+	Node               []KeyableString      `mapstructure:"node"`
+	Wan                []Wan                `mapstructure:"wan" desc:"WanDesc"`
+	Group              []Group              `mapstructure:"group" desc:"GroupDesc"`
+	Routing            Routing              `mapstructure:"routing" required:""`
+	Dns                Dns                  `mapstructure:"dns" desc:"DnsDesc"`
+	Inbound            []Inbound            `mapstructure:"inbound" desc:"InboundDesc"`
 }
 
 // New params from sections. This func assumes merging (section "include") and deduplication for section names has been executed.
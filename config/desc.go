@@ -8,13 +8,18 @@ package config
 type Desc map[string]string
 
 var SectionSummaryDesc = Desc{
-	"subscription": "Subscriptions defined here will be resolved as nodes and merged as a part of the global node pool.\nSupport to give the subscription a tag, and filter nodes from a given subscription in the group section.",
-	"node":         "Nodes defined here will be merged as a part of the global node pool.",
-	"dns":          "See more at https://github.com/daeuniverse/dae/blob/main/docs/en/configuration/dns.md.",
-	"group":        "Node group. Groups defined here can be used as outbounds in section \"routing\".",
+	"subscription":        "Subscriptions defined here will be resolved as nodes and merged as a part of the global node pool.\nSupport to give the subscription a tag, and filter nodes from a given subscription in the group section.",
+	"subscription_option": "Options for subscriptions with the same tag in section \"subscription\".",
+	"node":                "Nodes defined here will be merged as a part of the global node pool.",
+	"wan":                 "Named direct dialers bound to WAN interfaces. They are merged as a part of the global node pool with the protocol \"direct\", and are checked like nodes, so that groups can use them for ISP failover or load balancing.",
+	"dns":                 "See more at https://github.com/daeuniverse/dae/blob/main/docs/en/configuration/dns.md.",
+	"group":               "Node group. Groups defined here can be used as outbounds in section \"routing\".",
+	"inbound":             "SOCKS5 and HTTP proxy inbounds on a mixed port, for clients that cannot be tproxied. Traffic from them follows section \"routing\" too.",
 	"routing": `Traffic follows this routing. See https://github.com/daeuniverse/dae/blob/main/docs/en/configuration/routing.md for full examples.
 Notice: domain traffic split will fail if DNS traffic is not taken over by dae.
-Built-in outbound: direct, must_direct, block.
+Built-in outbound: direct, must_direct, block, drop, reject(tcp_reset), reject(icmp), redirect('host:port').
+drop: Drop traffic silently. reject(tcp_reset) and reject(icmp): Reset TCP or reply ICMP port unreachable, so that clients fail fast.
+redirect: Send traffic to the given endpoint instead of its destination. With "proxy_protocol: v2", a PROXY protocol v2 header carrying the original source and destination is sent before TCP payload.
 Available functions: domain, sip, dip, sport, dport, ipversion, l4proto, pname, mac.
 Available keys in domain function: suffix, keyword, regex, full. No key indicates suffix.
 domain: Match domain.
@@ -29,9 +34,38 @@ mac: Match source MAC address. It works on LAN mode.`,
 }
 
 var SectionDescription = map[string]Desc{
-	"GlobalDesc": GlobalDesc,
-	"DnsDesc":    DnsDesc,
-	"GroupDesc":  GroupDesc,
+	"GlobalDesc":             GlobalDesc,
+	"DnsDesc":                DnsDesc,
+	"GroupDesc":              GroupDesc,
+	"SubscriptionOptionDesc": SubscriptionOptionDesc,
+	"WanDesc":                WanDesc,
+	"InboundDesc":            InboundDesc,
+}
+
+var InboundDesc = Desc{
+	"listen":   "Address to listen on for both SOCKS5 and HTTP proxy requests, such as \"0.0.0.0:1080\". SOCKS5 UDP ASSOCIATE is supported.",
+	"username": "Username of SOCKS5 and HTTP proxy authentication. No authentication is required if it is empty.",
+	"password": "Password of SOCKS5 and HTTP proxy authentication.",
+}
+
+var WanDesc = Desc{
+	"iface": "Interface to dial through by SO_BINDTODEVICE.",
+	"mark":  "Fwmark of traffic through this WAN, which overrides so_mark_from_dae and mark in routing. Use it with your own \"ip rule\" for policy routing.",
+}
+
+var SubscriptionOptionDesc = Desc{
+	"timeout": "Timeout of fetching, including all retries and backoff. Subscriptions are fetched concurrently.",
+	"retry":   "Retry times with exponential backoff if fetching fails by network errors or 5xx responses.",
+	"drop": `Drop nodes hitting the filter. Multiple drop lines are "or" relation. Not operator and "&&" are supported.
+Available functions: name, protocol, address.
+Available keys in name and address function: keyword, regex. No key indicates full match.
+protocol: Match the scheme of node link, such as vmess, ss, trojan, hysteria2.
+address: Match the server host of node.`,
+	"rename": `Rename nodes in order. Use replace(regex: ..., with: ...) or replace(keyword: ..., with: ...).
+Groups like $1 can be used in "with" of regex rules.`,
+	"dedupe":     "Drop nodes whose protocol, server and credential are the same as a node from the \"node\" section or a subscription resolved before.",
+	"tag_prefix": "Prefix node names with the subscription tag, like \"[my_sub] node name\".",
+	"via":        "Fetch the subscription through a node of the given group. It falls back to direct if no node of the group works. Nodes of the group can only come from the \"node\" section and subscriptions fetched without \"via\" at the first fetching, and alive nodes of the running group are preferred on reload.",
 }
 
 var GlobalDesc = Desc{
@@ -42,7 +76,8 @@ var GlobalDesc = Desc{
 	"tcp_check_url":         "Node connectivity check.\nHost of URL should have both IPv4 and IPv6 if you have double stack in local.\nConsidering traffic consumption, it is recommended to choose a site with anycast IP and less response.",
 	"tcp_check_http_method": "The HTTP request method to `tcp_check_url`. Use 'HEAD' by default because some server implementations bypass accounting for this kind of traffic.",
 	"udp_check_dns":         "This DNS will be used to check UDP connectivity of nodes. And if dns_upstream below contains tcp, it also be used to check TCP DNS connectivity of nodes.\nThis DNS should have both IPv4 and IPv6 if you have double stack in local.",
-	"check_interval":        "Interval of connectivity check for TCP and UDP",
+	"check_interval":        "Interval of connectivity check for TCP and UDP. Failing or recovering nodes are checked more often, and nodes only in groups without traffic for 10 intervals are checked less often, up to 8 intervals.",
+	"check_concurrency":     "Max number of concurrent connectivity checks across all nodes. 0 indicates no limit.",
 	"check_tolerance":       "Group will switch node only when new_latency <= old_latency - tolerance.",
 	"lan_interface":         "The LAN interface to bind. Use it if you want to proxy LAN.",
 	"wan_interface":         "The WAN interface to bind. Use it if you want to proxy localhost. Use \"auto\" to auto detect.",
@@ -52,12 +87,17 @@ var GlobalDesc = Desc{
 2. "domain". Dial proxy using the domain from sniffing. This will relieve DNS pollution problem to a great extent if have impure DNS environment. Generally, this mode brings faster proxy response time because proxy will re-resolve the domain in remote, thus get better IP result to connect. This policy does not impact routing. That is to say, domain rewrite will be after traffic split of routing and dae will not re-route it.
 3. "domain+". Based on domain mode but do not check the reality of sniffed domain. It is useful for users whose DNS requests do not go through dae but want faster proxy response time. Notice that, if DNS requests do not go through dae, dae cannot split traffic by domain.
 4. "domain++". Based on domain+ mode but force to re-route traffic using sniffed domain to partially recover domain based traffic split ability. It doesn't work for direct traffic and consumes more CPU resources.`,
+	"udp_nat_mode": `Optional values of udp_nat_mode are:
+1. "full_cone". All destinations of a client socket share one UDP endpoint through the dialer chosen by the first packet. This is friendly to games and P2P, but later destinations follow the outbound of the first one.
+2. "per_destination". Every (source, destination) pair has its own endpoint, so that different destinations from the same client socket can be routed to different outbounds. It can be overridden by routing rules, e.g. "dport(3478) -> my_group(udp_nat_mode: per_destination)".`,
 	"disable_waiting_network":      "Disable waiting for network before pulling subscriptions.",
 	"auto_config_kernel_parameter": "Automatically configure Linux kernel parameters like ip_forward and send_redirects. Check out https://github.com/daeuniverse/dae/blob/main/docs/en/user-guide/kernel-parameters.md to see what will dae do.",
 	"sniffing_timeout":             "Timeout to waiting for first data sending for sniffing. It is always 0 if dial_mode is ip. Set it higher is useful in high latency LAN network.",
 	"tls_implementation":           "TLS implementation. \"tls\" is to use Go's crypto/tls. \"utls\" is to use uTLS, which can imitate browser's Client Hello.",
 	"utls_imitate":                 "The Client Hello ID for uTLS to imitate. This takes effect only if tls_implementation is utls. See more: https://github.com/daeuniverse/dae/blob/331fa23c16/component/outbound/transport/tls/utls.go#L17",
 	"mptcp":                        "Enable Multipath TCP.  If is true, dae will try to use MPTCP to connect all nodes, but it will only take effects when the node supports MPTCP. It can use for load balance and failover to multiple interfaces and IPs.",
+	"subscription_traffic_warning": "Warn if remaining traffic of a subscription is less than this percent of its total. The traffic information is from the Subscription-Userinfo header or SIP008 bytes fields. Set it 0 to disable.",
+	"subscription_expire_warning":  "Warn if a subscription will expire within this duration. Set it 0 to disable.",
 }
 
 var DnsDesc = Desc{
@@ -74,20 +114,43 @@ Available functions: qname, qtype, ip, upstream`,
 
 var GroupDesc = Desc{
 	"filter": `Filter nodes from the global node pool defined by the "subscription" and "node" sections.
-Available functions: name, subtag. Not operator is supported.
+Available functions: name, subtag, protocol, address, port, server_geoip, link, group. Not operator is supported.
 Available keys in name function: keyword, regex. No key indicates full match.
-Available keys in subtag function: regex. No key indicates full match.`,
+Available keys in subtag function: regex. No key indicates full match.
+protocol function matches protocols of nodes, like protocol(vmess, hysteria2). Aliases ss, ssr, hy2 and socks are supported.
+Available keys in address function: keyword, regex. No key indicates full match. It matches the server host of nodes.
+port function matches server ports of nodes, like port(443, 8000-9000).
+server_geoip function resolves server hosts of nodes and matches them with geoip.dat, like server_geoip(hk, jp). Hosts are resolved again on reload and subscription refresh.
+Available keys in link function: keyword, regex. No key indicates full match.
+group function takes other groups as members, like group(hk_group, jp_group). A member group is dialed through its current selection, and its latency is checked through it. Only filters with group function hit groups.`,
 	"policy": `Dialer selection policy. For each new connection, select a node as dialer from group by this policy.
-Available values: random, fixed, min, min_avg10, min_moving_avg.
+Available values: random, fixed, min, min_avg10, min_avg10_with_loss, min_moving_avg, sticky, balance, failover, select.
 random: Select randomly.
 fixed: Select the fixed node. Connectivity check will be disabled.
 min: Select node by the latency of last check.
 min_avg10: Select node by the average of latencies of last 10 checks.
+min_avg10_with_loss: Like min_avg10, but failed checks are counted as loss instead of latency, and nodes whose loss of last 10 checks exceeds max_loss are excluded. Available param: max_loss (default: 20%). For example: min_avg10_with_loss(max_loss: 20%).
 min_moving_avg: Select node by the moving average of latencies of checks, which means more recent latencies have higher weight.
+sticky: Select the same alive node for the same client by consistent hashing, and only move the client when its node dies. Available params: key (src_ip, mac or src_ip+dst_domain; default: src_ip) and ttl (forget the client after being idle for ttl; default: 30m). For example: sticky(key: mac, ttl: 1h).
+balance: Spread connections across all alive nodes randomly, weighted by the inverse moving average of latencies, the inverse number of connections in flight and the filter annotation "weight" (default: 1).
+failover: Select the first alive node in the declaration order of filters, and fall back down the list. Nodes hitting the same filter are in the order of the node pool. Available param: revert_after (do not revert to a recovered node until it has been alive for revert_after; default: 0s). For example: failover(revert_after: 5m).
+select: Select the node chosen at runtime by "dae select <group> <node>", which is persisted to selection.json in the config directory. Fall back to another policy if no node is chosen or the chosen node is not alive. Available param: fallback (random, min, min_avg10, min_moving_avg, balance or failover; default: min_moving_avg). For example: select(fallback: min).
 `,
+	"dialer_via":            "Dial every node in this group through the currently selected node of another group, like chaining each node after the selection. Connectivity checks of nodes go through the chain too. Group members by group function are not supported.",
+	"on_fail":               "Another group to dial through when dialing fails with all retried nodes of this group. Failed dials are retried with the next best alive nodes of the group first. Retries share the connect timeout of the client and only happen before any payload is relayed.",
 	"tcp_check_url":         "Override global config.",
 	"tcp_check_http_method": "Override global config.",
 	"udp_check_dns":         "Override global config.",
 	"check_interval":        "Override global config.",
 	"check_tolerance":       "Override global config.",
+	"allow_insecure":        "Override global config.",
+	"tls_implementation":    "Override global config.",
+	"utls_imitate":          "Override global config.",
+	"tls_fragment":          "Override global config.",
+	"tls_fragment_length":   "Override global config.",
+	"tls_fragment_interval": "Override global config.",
+	"bandwidth_max_tx":      "Override global config.",
+	"bandwidth_max_rx":      "Override global config.",
+	"udphop_interval":       "Override global config.",
+	"mptcp":                 "Override global config.",
 }
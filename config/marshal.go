@@ -144,6 +144,11 @@ func (m *Marshaller) marshalLeaf(key string, from reflect.Value, depth int) (err
 		return nil
 	}
 	switch from.Kind() {
+	case reflect.Pointer:
+		if from.IsNil() {
+			return nil
+		}
+		return m.marshalLeaf(key, from.Elem(), depth)
 	case reflect.Slice:
 		if from.Len() == 0 {
 			return nil
@@ -0,0 +1,43 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package config
+
+import (
+	"testing"
+
+	"github.com/daeuniverse/dae/pkg/config_parser"
+)
+
+func TestGroupOverride(t *testing.T) {
+	sections, err := config_parser.Parse(`
+global {}
+routing {}
+group {
+    hy2 {
+        policy: min
+        tls_fragment: false
+        bandwidth_max_tx: '50 mbps'
+    }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := New(sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := conf.Group[0]
+	if g.TlsFragment == nil || *g.TlsFragment {
+		t.Errorf("expected tls_fragment set to false, got %v", g.TlsFragment)
+	}
+	if g.AllowInsecure != nil || g.Mptcp != nil {
+		t.Errorf("expected unset options to be nil")
+	}
+	if g.BandwidthMaxTx != "50 mbps" {
+		t.Errorf("unexpected bandwidth_max_tx: %v", g.BandwidthMaxTx)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -54,12 +55,14 @@ type ControlPlane struct {
 
 	// TODO: add mutex?
 	outbounds     []*outbound.DialerGroup
+	dialerSet     *outbound.DialerSet
 	inConnections sync.Map
 
 	dnsController    *DnsController
 	onceNetworkReady sync.Once
 
-	dialMode consts.DialMode
+	dialMode   consts.DialMode
+	udpNatMode consts.UdpNatMode
 
 	routingMatcher *RoutingMatcher
 
@@ -77,6 +80,14 @@ type ControlPlane struct {
 	tproxyPortProtect bool
 	soMarkFromDae     uint32
 	mptcp             bool
+
+	inbounds []config.Inbound
+	// redirects are options of redirect outbounds, which are created on demand by routing rules.
+	redirects map[consts.OutboundIndex]*routing.Redirect
+
+	// latencyStatePath is where the check history is persisted. It is empty if not persisting.
+	latencyStatePath string
+	latencyStateMu   sync.Mutex
 }
 
 func NewControlPlane(
@@ -84,10 +95,14 @@ func NewControlPlane(
 	_bpf interface{},
 	dnsCache map[string]*DnsCache,
 	tagToNodeList map[string][]string,
+	nodeAnnotations map[string][]*config_parser.Param,
+	latencyState *outbound.LatencyState,
+	wans []config.Wan,
 	groups []config.Group,
 	routingA *config.Routing,
 	global *config.Global,
 	dnsConfig *config.Dns,
+	inbounds []config.Inbound,
 	externGeoDataDirs []string,
 ) (*ControlPlane, error) {
 	// TODO: Some users reported that enabling GSO on the client would affect the performance of watching YouTube, so we disabled it by default.
@@ -271,6 +286,10 @@ func NewControlPlane(
 		sniffingTimeout = 0
 	}
 	disableKernelAliveCallback := dialMode != consts.DialMode_Ip
+	udpNatMode, err := consts.ParseUdpNatMode(global.UdpNatMode)
+	if err != nil {
+		return nil, err
+	}
 	_direct, directProperty := dialer.NewDirectDialer(option, true)
 	direct := dialer.NewDialer(_direct, option, dialer.InstanceOption{DisableCheck: true}, directProperty)
 	_block, blockProperty := dialer.NewBlockDialer(option, func() { /*Dialer Outbound*/ })
@@ -281,22 +300,38 @@ func NewControlPlane(
 			outbound.DialerSelectionPolicy{
 				Policy:     consts.DialerSelectionPolicy_Fixed,
 				FixedIndex: 0,
-			}, core.outboundAliveChangeCallback(0, disableKernelAliveCallback)),
+			}, core.outboundAliveChangeCallback(uint8(consts.OutboundDirect), disableKernelAliveCallback)),
 		outbound.NewDialerGroup(option, consts.OutboundBlock.String(),
 			[]*dialer.Dialer{block}, []*dialer.Annotation{{}},
 			outbound.DialerSelectionPolicy{
 				Policy:     consts.DialerSelectionPolicy_Fixed,
 				FixedIndex: 0,
-			}, core.outboundAliveChangeCallback(1, disableKernelAliveCallback)),
+			}, core.outboundAliveChangeCallback(uint8(consts.OutboundBlock), disableKernelAliveCallback)),
 	}
 
 	// Filter out groups.
 	// FIXME: Ugly code here: reset grpc and meek clients manually.
 	grpc.CleanGlobalClientConnectionCache()
 	meek.CleanGlobalRoundTripperCache()
-	dialerSet := outbound.NewDialerSetFromLinks(option, tagToNodeList)
+	locationFinder := assets.NewLocationFinder(externGeoDataDirs)
+	dialerSet := outbound.NewDialerSetFromLinks(option, tagToNodeList, nodeAnnotations, wans, locationFinder, latencyState)
 	deferFuncs = append(deferFuncs, dialerSet.Close)
 	for _, group := range groups {
+		switch group.Name {
+		case consts.OutboundDirect.String(), consts.OutboundBlock.String(), consts.OutboundDrop.String(),
+			consts.OutboundReject, consts.OutboundRedirect, consts.OutboundMustRules.String():
+			return nil, fmt.Errorf(`group name "%v" is reserved by the built-in outbound`, group.Name)
+		}
+	}
+	// Create groups in the order of references, so that groups are created before the groups filtering them by
+	// "group()".
+	groupOrder, referenced, err := outbound.SortGroupsByReference(groups)
+	if err != nil {
+		return nil, err
+	}
+	groupOutbounds := make([]*outbound.DialerGroup, len(groups))
+	for _, i := range groupOrder {
+		group := groups[i]
 		// Parse policy.
 		policy, err := outbound.NewDialerSelectionPolicyFromGroupParam(&group)
 		if err != nil {
@@ -318,21 +353,101 @@ func NewControlPlane(
 		groupOption, err := ParseGroupOverrideOption(group, *global, log)
 		finalOption := option
 		if err == nil && groupOption != nil {
+			// Options of nodes are used to create dialers from links.
+			recreate := groupOption.ExtraOption != option.ExtraOption
 			newDialers := make([]*dialer.Dialer, 0)
 			for _, d := range dialers {
-				newDialer := d.Clone()
+				var newDialer *dialer.Dialer
+				// Groups and WANs are not created from links, and options of nodes do not apply to them.
+				if recreate && d.Property().Protocol != outbound.GroupDialerProtocol && d.Property().Protocol != dialer.WanProtocol {
+					if newDialer, err = d.CloneWithOption(groupOption); err != nil {
+						return nil, fmt.Errorf(`failed to create group "%v": override options of "%v": %w`, group.Name, d.Property().Name, err)
+					}
+				} else {
+					newDialer = d.Clone()
+					newDialer.GlobalOption = groupOption
+				}
 				deferFuncs = append(deferFuncs, newDialer.Close)
-				newDialer.GlobalOption = groupOption
 				newDialers = append(newDialers, newDialer)
 			}
 			log.Infof(`Group "%v"'s check option has been override.`, group.Name)
 			dialers = newDialers
 			finalOption = groupOption
 		}
+		if group.DialerVia != "" {
+			// Chain nodes after the current selection of the via group. They are checked through the chain too.
+			var via *outbound.DialerGroup
+			for j := range groups {
+				if groups[j].Name == group.DialerVia {
+					via = groupOutbounds[j]
+					break
+				}
+			}
+			viaDialer := outbound.NewViaDialer(via)
+			newDialers := make([]*dialer.Dialer, 0, len(dialers))
+			for _, d := range dialers {
+				if d.Property().Protocol == outbound.GroupDialerProtocol || d.Property().Protocol == dialer.WanProtocol {
+					return nil, fmt.Errorf(`failed to create group "%v": dialer_via does not support group or wan member "%v"`, group.Name, d.Property().Name)
+				}
+				newDialer, err := d.CloneVia(viaDialer)
+				if err != nil {
+					return nil, fmt.Errorf(`failed to create group "%v": chain "%v" via group "%v": %w`, group.Name, d.Property().Name, group.DialerVia, err)
+				}
+				deferFuncs = append(deferFuncs, newDialer.Close)
+				newDialers = append(newDialers, newDialer)
+			}
+			log.Infof(`Group "%v" is dialed via group "%v".`, group.Name, group.DialerVia)
+			dialers = newDialers
+		}
 		// Create dialer group and append it to outbounds.
 		dialerGroup := outbound.NewDialerGroup(finalOption, group.Name, dialers, annos, *policy,
-			core.outboundAliveChangeCallback(uint8(len(outbounds)), disableKernelAliveCallback))
-		outbounds = append(outbounds, dialerGroup)
+			core.outboundAliveChangeCallback(uint8(len(outbounds)+i), disableKernelAliveCallback))
+		groupOutbounds[i] = dialerGroup
+		if referenced[i] {
+			groupDialer := outbound.NewGroupDialer(option, dialerGroup)
+			deferFuncs = append(deferFuncs, groupDialer.Close)
+			dialerSet.AddGroupDialer(groupDialer)
+		}
+	}
+	for i, group := range groups {
+		if group.OnFail == "" {
+			continue
+		}
+		j := slices.IndexFunc(groups, func(g config.Group) bool { return g.Name == group.OnFail })
+		if j < 0 || j == i {
+			return nil, fmt.Errorf(`group "%v": on_fail group "%v" does not exist or is itself`, group.Name, group.OnFail)
+		}
+		groupOutbounds[i].OnFail = groupOutbounds[j]
+	}
+	outbounds = append(outbounds, groupOutbounds...)
+	// Create an outbound for every redirect target referenced by routing.
+	redirects := make(map[consts.OutboundIndex]*routing.Redirect)
+	redirectFunctions := []*config_parser.Function{config.FunctionOrStringToFunction(routingA.Fallback)}
+	for _, rule := range routingA.Rules {
+		redirectFunctions = append(redirectFunctions, &rule.Outbound)
+	}
+	for _, f := range redirectFunctions {
+		if f.Name != consts.OutboundRedirect {
+			continue
+		}
+		o, err := routing.ParseOutbound(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse outbound %v: %w", f.String(false, false, false), err)
+		}
+		if slices.ContainsFunc(outbounds, func(g *outbound.DialerGroup) bool { return g.Name == o.Name }) {
+			continue
+		}
+		index := consts.OutboundIndex(len(outbounds))
+		_redirect, redirectProperty := dialer.NewRedirectDialer(option, o.Redirect.Target)
+		redirect := dialer.NewDialer(_redirect, option, dialer.InstanceOption{DisableCheck: true}, redirectProperty)
+		deferFuncs = append(deferFuncs, redirect.Close)
+		outbounds = append(outbounds, outbound.NewDialerGroup(option, o.Name,
+			[]*dialer.Dialer{redirect}, []*dialer.Annotation{{}},
+			outbound.DialerSelectionPolicy{
+				Policy:     consts.DialerSelectionPolicy_Fixed,
+				FixedIndex: 0,
+			}, core.outboundAliveChangeCallback(uint8(index), disableKernelAliveCallback)))
+		redirects[index] = o.Redirect
 	}
 
 	/// Routing.
@@ -348,8 +463,12 @@ func NewControlPlane(
 		outboundName2Id[o.Name] = uint8(i)
 		outboundId2Name[uint8(i)] = o.Name
 	}
+	// Drop and reject are handled before dialing and have no dialer groups.
+	for _, o := range []consts.OutboundIndex{consts.OutboundDrop, consts.OutboundRejectTcpReset, consts.OutboundRejectIcmp} {
+		outboundName2Id[o.String()] = uint8(o)
+		outboundId2Name[uint8(o)] = o.String()
+	}
 	// Apply rules optimizers.
-	locationFinder := assets.NewLocationFinder(externGeoDataDirs)
 	var rules []*config_parser.RoutingRule
 	if rules, err = routing.ApplyRulesOptimizers(routingA.Rules,
 		&routing.AliasOptimizer{},
@@ -388,9 +507,11 @@ func NewControlPlane(
 		deferFuncs:        deferFuncs,
 		listenIp:          "0.0.0.0",
 		outbounds:         outbounds,
+		dialerSet:         dialerSet,
 		dnsController:     nil,
 		onceNetworkReady:  sync.Once{},
 		dialMode:          dialMode,
+		udpNatMode:        udpNatMode,
 		routingMatcher:    routingMatcher,
 		ctx:               ctx,
 		cancel:            cancel,
@@ -403,6 +524,8 @@ func NewControlPlane(
 		tproxyPortProtect: global.TproxyPortProtect,
 		soMarkFromDae:     global.SoMarkFromDae,
 		mptcp:             global.Mptcp,
+		inbounds:          inbounds,
+		redirects:         redirects,
 	}
 	defer func() {
 		if err != nil {
@@ -544,6 +667,46 @@ func ParseGroupOverrideOption(group config.Group, global config.Global, log *log
 		result.CheckTolerance = group.CheckTolerance
 		changed = true
 	}
+	if group.AllowInsecure != nil {
+		result.AllowInsecure = *group.AllowInsecure
+		changed = true
+	}
+	if group.TlsImplementation != "" {
+		result.TlsImplementation = group.TlsImplementation
+		changed = true
+	}
+	if group.UtlsImitate != "" {
+		result.UtlsImitate = group.UtlsImitate
+		changed = true
+	}
+	if group.TlsFragment != nil {
+		result.TlsFragment = *group.TlsFragment
+		changed = true
+	}
+	if group.TlsFragmentLength != "" {
+		result.TlsFragmentLength = group.TlsFragmentLength
+		changed = true
+	}
+	if group.TlsFragmentInterval != "" {
+		result.TlsFragmentInterval = group.TlsFragmentInterval
+		changed = true
+	}
+	if group.BandwidthMaxTx != "" {
+		result.BandwidthMaxTx = group.BandwidthMaxTx
+		changed = true
+	}
+	if group.BandwidthMaxRx != "" {
+		result.BandwidthMaxRx = group.BandwidthMaxRx
+		changed = true
+	}
+	if group.UDPHopInterval != 0 {
+		result.UDPHopInterval = group.UDPHopInterval
+		changed = true
+	}
+	if group.Mptcp != nil {
+		result.Mptcp = *group.Mptcp
+		changed = true
+	}
 	if changed {
 		option := dialer.NewGlobalOption(&result, log)
 		return option, nil
@@ -632,6 +795,26 @@ func (c *ControlPlane) ActivateCheck() {
 		}
 	}
 }
+
+// SelectGroupDialer selects an alive dialer for TCP from the user defined group with given name.
+func (c *ControlPlane) SelectGroupDialer(name string) (d *dialer.Dialer, err error) {
+	for _, g := range c.outbounds[consts.OutboundUserDefinedMin:] {
+		if g.Name != name {
+			continue
+		}
+		d, _, err = g.Select(&dialer.NetworkType{
+			L4Proto:   consts.L4ProtoStr_TCP,
+			IpVersion: consts.IpVersionStr_4,
+			IsDns:     false,
+		}, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select dialer from group %v: %w", name, err)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("group %v does not exist", name)
+}
+
 func (c *ControlPlane) ChooseDialTarget(outbound consts.OutboundIndex, dst netip.AddrPort, domain string) (dialTarget string, shouldReroute bool, dialIp bool) {
 	dialMode := consts.DialMode_Ip
 
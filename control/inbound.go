@@ -0,0 +1,403 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daeuniverse/dae/common"
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/common/netutils"
+	"github.com/daeuniverse/dae/component/sniffing"
+	"github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/outbound/protocol/direct"
+)
+
+const (
+	// InboundHandshakeTimeout limits the time for clients of inbounds to send their requests.
+	InboundHandshakeTimeout = 10 * time.Second
+
+	socks5Version          = 0x05
+	socks5AuthNone         = 0x00
+	socks5AuthPassword     = 0x02
+	socks5AuthNoAcceptable = 0xFF
+	socks5CmdConnect       = 0x01
+	socks5CmdUdpAssociate  = 0x03
+	socks5AtypIpv4         = 0x01
+	socks5AtypDomain       = 0x03
+	socks5AtypIpv6         = 0x04
+
+	socks5RepSucceeded           = 0x00
+	socks5RepHostUnreachable     = 0x04
+	socks5RepCmdNotSupported     = 0x07
+	socks5RepAddrTypeUnsupported = 0x08
+)
+
+// ListenInbounds listens on the SOCKS5/HTTP mixed ports in section "inbound". Listeners are closed with the control
+// plane. It should be called in the host network namespace.
+func (c *ControlPlane) ListenInbounds() error {
+	for i := range c.inbounds {
+		in := &c.inbounds[i]
+		ln, err := net.Listen("tcp", in.Listen)
+		if err != nil {
+			return fmt.Errorf(`failed to listen inbound "%v": %w`, in.Name, err)
+		}
+		c.deferFuncs = append(c.deferFuncs, ln.Close)
+		c.log.Infof(`Inbound "%v" is listening on %v`, in.Name, ln.Addr())
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					if !errors.Is(err, net.ErrClosed) {
+						c.log.Errorf("Error when accept inbound %v: %v", in.Name, err)
+					}
+					return
+				}
+				go func() {
+					c.inConnections.Store(conn, struct{}{})
+					defer c.inConnections.Delete(conn)
+					if err := c.handleInboundConn(in, conn); err != nil {
+						c.log.Warnf("handleInboundConn(%v): %v", in.Name, err)
+					}
+				}()
+			}
+		}()
+	}
+	return nil
+}
+
+// bufferedConn reads from r, which holds data buffered while reading requests of clients.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if conn, ok := c.Conn.(WriteCloser); ok {
+		return conn.CloseWrite()
+	}
+	return nil
+}
+
+func (c *ControlPlane) handleInboundConn(in *config.Inbound, conn net.Conn) error {
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(InboundHandshakeTimeout))
+	br := bufio.NewReader(conn)
+	b, err := br.Peek(1)
+	if err != nil {
+		return nil
+	}
+	if b[0] == socks5Version {
+		return c.handleSocks5(in, conn, br)
+	}
+	return c.handleHttpProxy(in, conn, br)
+}
+
+// resolveInboundTarget returns the address to route and the domain of the target of inbound clients. Domains are
+// resolved by the system DNS to be routed by IP rules, like sniffed traffic.
+func (c *ControlPlane) resolveInboundTarget(host string, port uint16) (dst netip.AddrPort, domain string, err error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return netip.AddrPortFrom(ip, port), "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), consts.DefaultDialTimeout)
+	defer cancel()
+	systemDns, err := netutils.SystemDns()
+	if err != nil {
+		return netip.AddrPort{}, "", err
+	}
+	ip46, err4, err6 := netutils.ResolveIp46(ctx, direct.SymmetricDirect, systemDns, host, common.MagicNetwork("udp", c.soMarkFromDae, c.mptcp), false)
+	switch {
+	case ip46.Ip4.IsValid():
+		return netip.AddrPortFrom(ip46.Ip4, port), host, nil
+	case ip46.Ip6.IsValid():
+		return netip.AddrPortFrom(ip46.Ip6, port), host, nil
+	default:
+		return netip.AddrPort{}, "", fmt.Errorf("failed to resolve %v: %w", host, errors.Join(err4, err6))
+	}
+}
+
+// relayInboundTcp routes, dials and relays a TCP connection of inbound clients like a tproxied one. rawConn is used to
+// reset the connection for outbound reject.
+func (c *ControlPlane) relayInboundTcp(rawConn net.Conn, lConn net.Conn, dst netip.AddrPort, domain string) (err error) {
+	_ = rawConn.SetReadDeadline(time.Time{})
+	src := common.ConvergeAddrPort(rawConn.RemoteAddr().(*net.TCPAddr).AddrPort())
+	dst = common.ConvergeAddrPort(dst)
+	if domain == "" {
+		// Sniff target domain.
+		sniffer := sniffing.NewConnSniffer(lConn, c.sniffingTimeout)
+		defer sniffer.Close()
+		if domain, err = sniffer.SniffTcp(); err != nil && !sniffing.IsSniffingError(err) {
+			return err
+		}
+		lConn = sniffer
+	}
+	rConn, release, err := c.routeDialTcp(&RouteDialParam{
+		Outbound: consts.OutboundControlPlaneRouting,
+		Domain:   domain,
+		Src:      src,
+		Dest:     dst,
+	})
+	if err != nil {
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			if rejectErr.Outbound != consts.OutboundDrop {
+				resetTcp(rawConn)
+			}
+			return nil
+		}
+		return fmt.Errorf("failed to dial %v: %w", dst, err)
+	}
+	defer rConn.Close()
+	defer release()
+
+	return relayConn(lConn, rConn)
+}
+
+func (c *ControlPlane) handleSocks5(in *config.Inbound, conn net.Conn, br *bufio.Reader) error {
+	// Negotiate the method.
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return nil
+	}
+	method := byte(socks5AuthNone)
+	if in.Username != "" {
+		method = socks5AuthPassword
+	}
+	if bytes.IndexByte(methods, method) == -1 {
+		_, _ = conn.Write([]byte{socks5Version, socks5AuthNoAcceptable})
+		return fmt.Errorf("no acceptable socks5 method from %v", conn.RemoteAddr())
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return nil
+	}
+	if method == socks5AuthPassword {
+		// RFC 1929.
+		username, password, err := readSocks5Password(br)
+		if err != nil {
+			return nil
+		}
+		if !checkInboundAuth(in, username, password) {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			return fmt.Errorf("bad socks5 username or password from %v", conn.RemoteAddr())
+		}
+		if _, err = conn.Write([]byte{0x01, 0x00}); err != nil {
+			return nil
+		}
+	}
+
+	// Read the request.
+	var req [3]byte
+	if _, err := io.ReadFull(br, req[:]); err != nil {
+		return nil
+	}
+	host, port, err := readSocks5Addr(br)
+	if err != nil {
+		_ = writeSocks5Reply(conn, socks5RepAddrTypeUnsupported, netip.AddrPort{})
+		return err
+	}
+	switch req[1] {
+	case socks5CmdConnect:
+		dst, domain, err := c.resolveInboundTarget(host, port)
+		if err != nil {
+			_ = writeSocks5Reply(conn, socks5RepHostUnreachable, netip.AddrPort{})
+			return err
+		}
+		// Reply before dialing, so that clients send data to sniff.
+		if err = writeSocks5Reply(conn, socks5RepSucceeded, conn.LocalAddr().(*net.TCPAddr).AddrPort()); err != nil {
+			return nil
+		}
+		return c.relayInboundTcp(conn, &bufferedConn{Conn: conn, r: br}, dst, domain)
+	case socks5CmdUdpAssociate:
+		return c.handleSocks5UdpAssociate(conn)
+	default:
+		_ = writeSocks5Reply(conn, socks5RepCmdNotSupported, netip.AddrPort{})
+		return fmt.Errorf("unsupported socks5 command: %v", req[1])
+	}
+}
+
+func readSocks5Password(r io.Reader) (username, password string, err error) {
+	var b [2]byte
+	if _, err = io.ReadFull(r, b[:]); err != nil {
+		return "", "", err
+	}
+	u := make([]byte, b[1])
+	if _, err = io.ReadFull(r, u); err != nil {
+		return "", "", err
+	}
+	if _, err = io.ReadFull(r, b[:1]); err != nil {
+		return "", "", err
+	}
+	p := make([]byte, b[0])
+	if _, err = io.ReadFull(r, p); err != nil {
+		return "", "", err
+	}
+	return string(u), string(p), nil
+}
+
+// readSocks5Addr reads ATYP, DST.ADDR and DST.PORT of socks5 requests.
+func readSocks5Addr(r io.Reader) (host string, port uint16, err error) {
+	var atyp [1]byte
+	if _, err = io.ReadFull(r, atyp[:]); err != nil {
+		return "", 0, err
+	}
+	var addr []byte
+	switch atyp[0] {
+	case socks5AtypIpv4:
+		addr = make([]byte, 4)
+	case socks5AtypIpv6:
+		addr = make([]byte, 16)
+	case socks5AtypDomain:
+		var l [1]byte
+		if _, err = io.ReadFull(r, l[:]); err != nil {
+			return "", 0, err
+		}
+		addr = make([]byte, l[0])
+	default:
+		return "", 0, fmt.Errorf("unsupported socks5 address type: %v", atyp[0])
+	}
+	if _, err = io.ReadFull(r, addr); err != nil {
+		return "", 0, err
+	}
+	var p [2]byte
+	if _, err = io.ReadFull(r, p[:]); err != nil {
+		return "", 0, err
+	}
+	if atyp[0] == socks5AtypDomain {
+		host = string(addr)
+	} else {
+		ip, _ := netip.AddrFromSlice(addr)
+		host = ip.String()
+	}
+	return host, binary.BigEndian.Uint16(p[:]), nil
+}
+
+// appendSocks5Addr appends ATYP, ADDR and PORT of the address to b.
+func appendSocks5Addr(b []byte, addr netip.AddrPort) []byte {
+	ip := addr.Addr().Unmap()
+	if ip.Is4() {
+		b = append(b, socks5AtypIpv4)
+	} else {
+		b = append(b, socks5AtypIpv6)
+	}
+	b = append(b, ip.AsSlice()...)
+	return binary.BigEndian.AppendUint16(b, addr.Port())
+}
+
+func writeSocks5Reply(w io.Writer, rep byte, bind netip.AddrPort) error {
+	if !bind.IsValid() {
+		bind = netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+	}
+	_, err := w.Write(appendSocks5Addr([]byte{socks5Version, rep, 0x00}, bind))
+	return err
+}
+
+// checkInboundAuth reports whether the username and password match the inbound. Any of them matches if no username is set.
+func checkInboundAuth(in *config.Inbound, username, password string) bool {
+	if in.Username == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(username), []byte(in.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(in.Password)) == 1
+}
+
+func (c *ControlPlane) handleHttpProxy(in *config.Inbound, conn net.Conn, br *bufio.Reader) error {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil
+	}
+	if in.Username != "" {
+		username, password, ok := parseProxyAuthorization(req.Header.Get("Proxy-Authorization"))
+		if !ok || !checkInboundAuth(in, username, password) {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"dae\"\r\nContent-Length: 0\r\n\r\n")
+			return nil
+		}
+	}
+
+	if req.Method != http.MethodConnect && !req.URL.IsAbs() {
+		// It is not a proxy request, and the target is this inbound itself.
+		_, _ = io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+		return nil
+	}
+	hostport := req.Host
+	if req.URL.Host != "" {
+		hostport = req.URL.Host
+	}
+	defaultPort := "80"
+	if req.Method == http.MethodConnect {
+		defaultPort = "443"
+	}
+	host, strPort, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, strPort = strings.Trim(hostport, "[]"), defaultPort
+	}
+	port, err := strconv.ParseUint(strPort, 10, 16)
+	if err != nil || host == "" {
+		_, _ = io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+		return fmt.Errorf("bad http proxy target: %v", hostport)
+	}
+	dst, domain, err := c.resolveInboundTarget(host, uint16(port))
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return err
+	}
+
+	if req.Method == http.MethodConnect {
+		if _, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return nil
+		}
+		return c.relayInboundTcp(conn, &bufferedConn{Conn: conn, r: br}, dst, domain)
+	}
+
+	// Forward plain HTTP requests in origin-form. The body is left in br and relayed as it is.
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "%v %v %v\r\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(&head, "Host: %v\r\n", req.Host)
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Del("Proxy-Connection")
+	// Requests on the same connection may go to other hosts.
+	req.Header.Set("Connection", "close")
+	if len(req.TransferEncoding) > 0 {
+		req.Header.Set("Transfer-Encoding", strings.Join(req.TransferEncoding, ", "))
+	}
+	_ = req.Header.Write(&head)
+	head.WriteString("\r\n")
+	return c.relayInboundTcp(conn, &bufferedConn{Conn: conn, r: io.MultiReader(&head, br)}, dst, domain)
+}
+
+func parseProxyAuthorization(auth string) (username, password string, ok bool) {
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok = strings.Cut(string(b), ":")
+	return username, password, ok
+}
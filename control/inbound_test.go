@@ -0,0 +1,54 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/daeuniverse/dae/config"
+)
+
+func TestSocks5Addr(t *testing.T) {
+	for _, addr := range []string{"1.2.3.4:80", "[2001:db8::1]:443", "[::ffff:1.2.3.4]:53"} {
+		ap := netip.MustParseAddrPort(addr)
+		host, port, err := readSocks5Addr(bytes.NewReader(appendSocks5Addr(nil, ap)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host != ap.Addr().Unmap().String() || port != ap.Port() {
+			t.Errorf("%v: got %v %v", addr, host, port)
+		}
+	}
+	host, port, err := readSocks5Addr(bytes.NewReader([]byte{socks5AtypDomain, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0x01, 0xbb}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "example.com" || port != 443 {
+		t.Errorf("got %v %v", host, port)
+	}
+	if _, _, err = readSocks5Addr(bytes.NewReader([]byte{0x05, 0, 0})); err == nil {
+		t.Errorf("expected error for bad address type")
+	}
+}
+
+func TestInboundAuth(t *testing.T) {
+	in := &config.Inbound{Username: "user", Password: "pass"}
+	username, password, ok := parseProxyAuthorization("Basic dXNlcjpwYXNz")
+	if !ok || !checkInboundAuth(in, username, password) {
+		t.Errorf("expected auth to pass: %v %v %v", username, password, ok)
+	}
+	if username, password, ok = parseProxyAuthorization("Basic dXNlcjpwYXNzMg=="); ok && checkInboundAuth(in, username, password) {
+		t.Errorf("expected bad password to fail")
+	}
+	if _, _, ok = parseProxyAuthorization("Bearer token"); ok {
+		t.Errorf("expected non-basic authorization to fail")
+	}
+	if !checkInboundAuth(&config.Inbound{}, "", "") {
+		t.Errorf("expected no auth to pass")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/daeuniverse/dae/common"
+	"github.com/daeuniverse/dae/common/consts"
+	ob "github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/outbound/pool"
+	"github.com/sirupsen/logrus"
+)
+
+// handleSocks5UdpAssociate relays UDP of the client until the TCP connection of the association is closed.
+func (c *ControlPlane) handleSocks5UdpAssociate(conn net.Conn) error {
+	local := conn.LocalAddr().(*net.TCPAddr).AddrPort()
+	udpConn, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.AddrPortFrom(local.Addr(), 0)))
+	if err != nil {
+		_ = writeSocks5Reply(conn, socks5RepHostUnreachable, netip.AddrPort{})
+		return err
+	}
+	defer udpConn.Close()
+	if err = writeSocks5Reply(conn, socks5RepSucceeded, udpConn.LocalAddr().(*net.UDPAddr).AddrPort()); err != nil {
+		return nil
+	}
+	clientIp := conn.RemoteAddr().(*net.TCPAddr).AddrPort().Addr().Unmap()
+	go func() {
+		_ = conn.SetReadDeadline(time.Time{})
+		_, _ = io.Copy(io.Discard, conn)
+		_ = udpConn.Close()
+	}()
+	return c.relayInboundUdp(udpConn, clientIp)
+}
+
+func (c *ControlPlane) relayInboundUdp(udpConn *net.UDPConn, clientIp netip.Addr) error {
+	// Endpoints are created per target, and routed like tproxied UDP.
+	endpoints := NewUdpEndpointPool()
+	defer endpoints.pool.Range(func(key, value any) bool {
+		_ = endpoints.Remove(key.(UdpEndpointKey), value.(*UdpEndpoint))
+		return true
+	})
+	resolved := make(map[string]netip.AddrPort)
+	buf := pool.GetFullCap(consts.EthernetMtu)
+	defer pool.Put(buf)
+	for {
+		n, client, err := udpConn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return nil
+		}
+		if client.Addr().Unmap() != clientIp {
+			continue
+		}
+		// +----+------+------+----------+----------+----------+
+		// |RSV | FRAG | ATYP | DST.ADDR | DST.PORT |   DATA   |
+		// +----+------+------+----------+----------+----------+
+		if n < 3 || buf[2] != 0 {
+			// Fragmentation is not supported.
+			continue
+		}
+		r := bytes.NewReader(buf[3:n])
+		host, port, err := readSocks5Addr(r)
+		if err != nil {
+			continue
+		}
+		target := net.JoinHostPort(host, fmt.Sprint(port))
+		dst, ok := resolved[target]
+		domain := ""
+		if _, e := netip.ParseAddr(host); e != nil {
+			domain = host
+		}
+		if !ok {
+			if dst, _, err = c.resolveInboundTarget(host, port); err != nil {
+				c.log.Debugf("relayInboundUdp: %v", err)
+				continue
+			}
+			resolved[target] = dst
+		}
+		data := buf[n-r.Len() : n]
+		if err = c.handleInboundPkt(endpoints, udpConn, client, common.ConvergeAddrPort(dst), domain, data); err != nil {
+			c.log.Debugf("relayInboundUdp: %v", err)
+		}
+	}
+}
+
+func (c *ControlPlane) handleInboundPkt(endpoints *UdpEndpointPool, udpConn *net.UDPConn, client, dst netip.AddrPort, domain string, data []byte) error {
+	networkType := &dialer.NetworkType{
+		L4Proto:   consts.L4ProtoStr_UDP,
+		IpVersion: consts.IpVersionFromAddr(dst.Addr()),
+		IsDns:     false,
+	}
+	ue, _, err := endpoints.GetOrCreate(UdpEndpointKey{Src: client, Dst: dst}, &UdpEndpointOptions{
+		// Handler wraps response packets and sends them to the client.
+		Handler: func(data []byte, from netip.AddrPort) error {
+			b := appendSocks5Addr(make([]byte, 3, 3+19+len(data)), from)
+			b = append(b, data...)
+			_, err := udpConn.WriteToUDPAddrPort(b, client)
+			return err
+		},
+		NatTimeout: DefaultNatTimeout,
+		GetDialOption: func() (option *DialOption, err error) {
+			routingResult := &bpfRoutingResult{}
+			outboundIndex, mark, _, err := c.Route(client, dst, domain, consts.L4ProtoType_UDP, routingResult)
+			if err != nil {
+				return nil, err
+			}
+			if outboundIndex.IsRejection() {
+				return nil, &RejectError{Outbound: outboundIndex}
+			}
+			if int(outboundIndex) >= len(c.outbounds) {
+				return nil, fmt.Errorf("outbound %v out of range [0, %v]", outboundIndex, len(c.outbounds)-1)
+			}
+			if mark == 0 {
+				mark = c.soMarkFromDae
+			}
+			outbound := c.outbounds[outboundIndex]
+			d, _, err := outbound.SelectFor(networkType, true, &ob.SelectOption{
+				Src:    client.Addr(),
+				Dst:    dst.Addr(),
+				Domain: domain,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to select dialer from group %v (%v): %w", outbound.Name, networkType.StringWithoutDns(), err)
+			}
+			if c.log.IsLevelEnabled(logrus.InfoLevel) {
+				c.log.WithFields(logrus.Fields{
+					"network":  networkType.StringWithoutDns(),
+					"outbound": outbound.Name,
+					"policy":   outbound.GetSelectionPolicy(),
+					"dialer":   d.Property().Name,
+					"sniffed":  domain,
+					"ip":       RefineAddrPortToShow(dst),
+				}).Infof("%v <-> %v", RefineSourceToShow(client, dst.Addr()), dst)
+			}
+			return &DialOption{
+				Target:        dst.String(),
+				Dialer:        d,
+				Outbound:      outbound,
+				NetworkType:   networkType,
+				Network:       common.MagicNetwork("udp", mark, c.mptcp),
+				SniffedDomain: domain,
+			}, nil
+		},
+	})
+	if err != nil {
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			// There is no way to reject UDP through socks5.
+			return nil
+		}
+		return err
+	}
+	if _, err = ue.WriteTo(data, ue.DialTarget); err != nil {
+		if observePassiveHealth(ue.Outbound) {
+			ue.Dialer.ReportDialFailure(networkType, ue.DialTarget, err)
+		}
+		return err
+	}
+	return nil
+}
@@ -55,6 +55,10 @@
 
 #define OUTBOUND_DIRECT 0
 #define OUTBOUND_BLOCK 1
+/* Reject outbounds need to answer clients, which is done by the control plane. */
+#define OUTBOUND_REJECT_ICMP 0xF9
+#define OUTBOUND_REJECT_TCP_RESET 0xFA
+#define OUTBOUND_DROP 0xFB
 #define OUTBOUND_MUST_RULES 0xFC
 #define OUTBOUND_CONTROL_PLANE_ROUTING 0xFD
 #define OUTBOUND_LOGICAL_OR 0xFE
@@ -133,6 +137,7 @@ struct routing_result {
 	__u8 pname[TASK_COMM_LEN];
 	__u32 pid;
 	__u8 dscp;
+	__u8 udp_nat_mode; // 0 is to follow the global config.
 };
 
 struct tuples_key {
@@ -267,6 +272,7 @@ struct match_set {
 	enum MatchType type;
 	__u8 outbound; // User-defined value range is [0, 252].
 	bool must;
+	__u8 udp_nat_mode;
 	__u32 mark;
 };
 
@@ -579,7 +585,7 @@ struct route_ctx {
 	const struct route_params *params;
 	__u16 h_dport;
 	__u16 h_sport;
-	__s64 result; // high -> low: sign(1b) unused(23b) mark(32b) outbound(8b)
+	__s64 result; // high -> low: sign(1b) unused(20b) udp_nat_mode(2b) must(1b) mark(32b) outbound(8b)
 	struct lpm_key lpm_key_saddr, lpm_key_daddr, lpm_key_mac;
 	volatile __u8 isdns_must_goodsubrule_badrule;
 };
@@ -794,7 +800,8 @@ before_next_loop:
 					ctx->result =
 						(__s64)OUTBOUND_CONTROL_PLANE_ROUTING |
 						((__s64)match_set->mark << 8) |
-						((__s64)must << 40);
+						((__s64)must << 40) |
+						((__s64)match_set->udp_nat_mode << 41);
 #ifdef __DEBUG_ROUTING
 					bpf_printk(
 						"OUTBOUND_CONTROL_PLANE_ROUTING: %ld",
@@ -804,7 +811,8 @@ before_next_loop:
 				}
 				ctx->result = (__s64)match_set->outbound |
 					      ((__s64)match_set->mark << 8) |
-					      ((__s64)must << 40);
+					      ((__s64)must << 40) |
+					      ((__s64)match_set->udp_nat_mode << 41);
 #ifdef __DEBUG_ROUTING
 				bpf_printk("outbound %u: %ld",
 					   match_set->outbound, ctx->result);
@@ -1168,6 +1176,7 @@ new_connection:;
 	routing_result.outbound = s64_ret;
 	routing_result.mark = s64_ret >> 8;
 	routing_result.must = (s64_ret >> 40) & 1;
+	routing_result.udp_nat_mode = (s64_ret >> 41) & 0b11;
 	routing_result.dscp = tuples.dscp;
 	__builtin_memcpy(routing_result.mac, ethh.h_source,
 			 sizeof(routing_result.mac));
@@ -1207,7 +1216,8 @@ new_connection:;
 		bpf_printk("GO OUTBOUND_DIRECT");
 #endif
 		goto direct;
-	} else if (unlikely(routing_result.outbound == OUTBOUND_BLOCK)) {
+	} else if (unlikely(routing_result.outbound == OUTBOUND_BLOCK ||
+			    routing_result.outbound == OUTBOUND_DROP)) {
 #if defined(__DEBUG_ROUTING) || defined(__PRINT_ROUTING_RESULT)
 		bpf_printk("SHOT OUTBOUND_BLOCK");
 #endif
@@ -1472,7 +1482,8 @@ static __always_inline int do_tproxy_wan_egress(struct __sk_buff *skb, u32 link_
 
 			skb->mark = mark;
 			return TC_ACT_OK;
-		} else if (unlikely(outbound == OUTBOUND_BLOCK)) {
+		} else if (unlikely(outbound == OUTBOUND_BLOCK ||
+				    outbound == OUTBOUND_DROP)) {
 #if defined(__DEBUG_ROUTING) || defined(__PRINT_ROUTING_RESULT)
 			bpf_printk("SHOT OUTBOUND_BLOCK");
 #endif
@@ -1572,6 +1583,7 @@ static __always_inline int do_tproxy_wan_egress(struct __sk_buff *skb, u32 link_
 		routing_result.outbound = s64_ret;
 		routing_result.mark = s64_ret >> 8;
 		routing_result.must = (s64_ret >> 40) & 1;
+		routing_result.udp_nat_mode = (s64_ret >> 41) & 0b11;
 		routing_result.dscp = tuples.dscp;
 		__builtin_memcpy(routing_result.mac, ethh.h_source,
 				 sizeof(ethh.h_source));
@@ -1599,8 +1611,8 @@ static __always_inline int do_tproxy_wan_egress(struct __sk_buff *skb, u32 link_
 		    // plane in WAN.
 		) {
 			return TC_ACT_OK;
-		} else if (unlikely(routing_result.outbound ==
-				    OUTBOUND_BLOCK)) {
+		} else if (unlikely(routing_result.outbound == OUTBOUND_BLOCK ||
+				    routing_result.outbound == OUTBOUND_DROP)) {
 			return TC_ACT_SHOT;
 		}
 
@@ -0,0 +1,65 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"time"
+
+	"github.com/daeuniverse/dae/component/outbound"
+)
+
+// LatencyStatePersistInterval is the interval to write the latency state file, which keeps check history across
+// crashes.
+const LatencyStatePersistInterval = 5 * time.Minute
+
+// LatencyState returns the check history of nodes, which is restored by the next control plane after reloading.
+func (c *ControlPlane) LatencyState() *outbound.LatencyState {
+	return c.dialerSet.LatencyState()
+}
+
+// PersistLatencyState writes the check history of nodes to path periodically and when the control plane is closed.
+// The previous control plane should hand over by HandOverLatencyState before it is called.
+func (c *ControlPlane) PersistLatencyState(path string) {
+	c.latencyStateMu.Lock()
+	c.latencyStatePath = path
+	c.latencyStateMu.Unlock()
+	// Write synchronously on close, otherwise the process may exit before the write.
+	c.deferFuncs = append(c.deferFuncs, func() error {
+		c.writeLatencyState()
+		return nil
+	})
+	go func() {
+		ticker := time.NewTicker(LatencyStatePersistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-ticker.C:
+				c.writeLatencyState()
+			}
+		}
+	}()
+}
+
+// HandOverLatencyState stops persisting the check history, which is taken over by the next control plane. It waits
+// for the writing in progress, so that the stale state never overwrites the newer one.
+func (c *ControlPlane) HandOverLatencyState() {
+	c.latencyStateMu.Lock()
+	c.latencyStatePath = ""
+	c.latencyStateMu.Unlock()
+}
+
+func (c *ControlPlane) writeLatencyState() {
+	c.latencyStateMu.Lock()
+	defer c.latencyStateMu.Unlock()
+	if c.latencyStatePath == "" {
+		return
+	}
+	if err := outbound.WriteLatencyState(c.latencyStatePath, c.LatencyState()); err != nil {
+		c.log.Warnf("Failed to write latency state: %v", err)
+	}
+}
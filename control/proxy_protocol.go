@@ -0,0 +1,40 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+var proxyProtocolV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// proxyProtocolV2Header builds the PROXY protocol v2 header of a TCP connection from src to dst, so that the
+// redirect target knows the original client and destination.
+func proxyProtocolV2Header(src, dst netip.AddrPort) []byte {
+	srcIp, dstIp := src.Addr().Unmap(), dst.Addr().Unmap()
+	b := make([]byte, 0, 16+36)
+	b = append(b, proxyProtocolV2Signature...)
+	// Version 2, command PROXY.
+	b = append(b, 0x21)
+	if srcIp.Is4() && dstIp.Is4() {
+		// AF_INET, STREAM.
+		b = append(b, 0x11)
+		b = binary.BigEndian.AppendUint16(b, 12)
+		b = append(b, srcIp.AsSlice()...)
+		b = append(b, dstIp.AsSlice()...)
+	} else {
+		// AF_INET6, STREAM. IPv4 addresses are mapped if versions are mixed.
+		b = append(b, 0x21)
+		b = binary.BigEndian.AppendUint16(b, 36)
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		b = append(b, s[:]...)
+		b = append(b, d[:]...)
+	}
+	b = binary.BigEndian.AppendUint16(b, src.Port())
+	b = binary.BigEndian.AppendUint16(b, dst.Port())
+	return b
+}
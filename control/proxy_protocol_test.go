@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/dae/component/routing"
+	"github.com/daeuniverse/outbound/protocol/direct"
+	"github.com/sirupsen/logrus"
+)
+
+func TestProxyProtocolV2Header(t *testing.T) {
+	header := proxyProtocolV2Header(netip.MustParseAddrPort("[::ffff:192.168.1.2]:50000"), netip.MustParseAddrPort("1.1.1.1:443"))
+	want, _ := hex.DecodeString("0d0a0d0a000d0a515549540a" + "2111000c" + "c0a80102" + "01010101" + "c350" + "01bb")
+	if !bytes.Equal(header, want) {
+		t.Errorf("bad ipv4 header: %x", header)
+	}
+
+	header = proxyProtocolV2Header(netip.MustParseAddrPort("192.168.1.2:50000"), netip.MustParseAddrPort("[2606:4700::1111]:443"))
+	if len(header) != 16+36 || header[13] != 0x21 || header[15] != 36 {
+		t.Fatalf("bad ipv6 header: %x", header)
+	}
+	if netip.AddrFrom16([16]byte(header[16:32])).Unmap() != netip.MustParseAddr("192.168.1.2") ||
+		netip.AddrFrom16([16]byte(header[32:48])) != netip.MustParseAddr("2606:4700::1111") {
+		t.Errorf("bad ipv6 addresses: %x", header[16:48])
+	}
+}
+
+func TestHandlePkt_RedirectDns(t *testing.T) {
+	target, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.MustParseAddrPort("127.0.0.1:0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	redirect := &routing.Redirect{Target: target.LocalAddr().String()}
+
+	direct.InitDirectDialers("127.0.0.1:53")
+	option := &dialer.GlobalOption{Log: logrus.New()}
+	_d, p := dialer.NewRedirectDialer(option, redirect.Target)
+	d := dialer.NewDialer(_d, option, dialer.InstanceOption{DisableCheck: true}, p)
+	defer d.Close()
+	index := consts.OutboundUserDefinedMin
+	c := newTestRoutingControlPlane(index)
+	c.outbounds = make([]*outbound.DialerGroup, index+1)
+	c.outbounds[index] = outbound.NewDialerGroup(option, redirect.String(), []*dialer.Dialer{d}, []*dialer.Annotation{{}},
+		outbound.DialerSelectionPolicy{Policy: consts.DialerSelectionPolicy_Fixed},
+		func(alive bool, networkType *dialer.NetworkType, isInit bool) {})
+	c.redirects = map[consts.OutboundIndex]*routing.Redirect{index: redirect}
+
+	// The query is not answered by dae, which has no DNS controller here.
+	src, dst := netip.MustParseAddrPort("127.0.0.1:50001"), netip.MustParseAddrPort("127.0.0.2:53")
+	query := newTestDnsQuery(t)
+	routingResult := &bpfRoutingResult{Outbound: uint8(consts.OutboundControlPlaneRouting)}
+	if err = c.handlePkt(nil, query, src, dst, dst, routingResult, false); err != nil {
+		t.Fatal(err)
+	}
+	if ue, ok := DefaultUdpEndpointPool.Get(UdpEndpointKey{Src: src}); ok {
+		defer DefaultUdpEndpointPool.Remove(UdpEndpointKey{Src: src}, ue)
+	}
+	buf := make([]byte, 512)
+	_ = target.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := target.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], query) {
+		t.Errorf("unexpected packet at the redirect target: %x", buf[:n])
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"golang.org/x/sys/unix"
+)
+
+// RejectError is returned by RouteDialTcp and the UDP dial option when traffic hits outbound drop or reject, so that
+// callers can drop or reject it instead of dialing.
+type RejectError struct {
+	Outbound consts.OutboundIndex
+}
+
+func (e *RejectError) Error() string {
+	return "traffic is rejected by outbound " + e.Outbound.String()
+}
+
+// resetTcp makes closing the conn send RST instead of FIN. An ICMP unreachable is useless here because the handshake has
+// been completed by the tproxy socket, so TCP is always reset for outbound reject.
+func resetTcp(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+}
+
+// maxIcmpQuote limits the quoted original datagram, so that the ICMP error fits the minimum MTU of IPv6.
+const maxIcmpQuote = 1280 - 40 - 8
+
+// sendUdpUnreachable sends an ICMP port unreachable to the client of a UDP packet from its original destination, like
+// the destination host has no service on the port.
+func sendUdpUnreachable(client, target netip.AddrPort, payload []byte) error {
+	pkt, err := udpUnreachablePacket(client, target, payload)
+	if err != nil {
+		return err
+	}
+	var (
+		domain   int
+		sockAddr unix.Sockaddr
+	)
+	if client.Addr().Unmap().Is4() {
+		domain = unix.AF_INET
+		sockAddr = &unix.SockaddrInet4{Addr: client.Addr().Unmap().As4()}
+	} else {
+		domain = unix.AF_INET6
+		sockAddr = &unix.SockaddrInet6{Addr: client.Addr().As16()}
+	}
+	// IPPROTO_RAW implies IP_HDRINCL, which allows to send from the address of the original destination.
+	fd, err := unix.Socket(domain, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		return fmt.Errorf("failed to create raw socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err = unix.Sendto(fd, pkt, 0, sockAddr); err != nil {
+		return fmt.Errorf("failed to send icmp unreachable: %w", err)
+	}
+	return nil
+}
+
+// udpUnreachablePacket builds the IP packet of an ICMP port unreachable for a UDP packet from client to target.
+func udpUnreachablePacket(client, target netip.AddrPort, payload []byte) ([]byte, error) {
+	client = netip.AddrPortFrom(client.Addr().Unmap(), client.Port())
+	target = netip.AddrPortFrom(target.Addr().Unmap(), target.Port())
+	if client.Addr().Is4() != target.Addr().Is4() {
+		return nil, fmt.Errorf("mismatched ip versions: %v and %v", client, target)
+	}
+	udpHeader := make([]byte, 8)
+	binary.BigEndian.PutUint16(udpHeader[0:], client.Port())
+	binary.BigEndian.PutUint16(udpHeader[2:], target.Port())
+	binary.BigEndian.PutUint16(udpHeader[4:], uint16(8+len(payload)))
+
+	if client.Addr().Is4() {
+		// The original datagram is quoted by its IP header and the first 64 bits of its data (RFC 792).
+		quote := append(ipv4Header(client.Addr(), target.Addr(), unix.IPPROTO_UDP, 8+len(payload)), udpHeader...)
+		icmpMsg := append([]byte{3 /* Destination Unreachable */, 3 /* Port Unreachable */, 0, 0, 0, 0, 0, 0}, quote...)
+		binary.BigEndian.PutUint16(icmpMsg[2:], checksum(icmpMsg, 0))
+		return append(ipv4Header(target.Addr(), client.Addr(), unix.IPPROTO_ICMP, len(icmpMsg)), icmpMsg...), nil
+	}
+	if len(payload) > maxIcmpQuote-40-8 {
+		payload = payload[:maxIcmpQuote-40-8]
+	}
+	quote := append(ipv6Header(client.Addr(), target.Addr(), unix.IPPROTO_UDP, 8+len(payload)), udpHeader...)
+	quote = append(quote, payload...)
+	icmpMsg := append([]byte{1 /* Destination Unreachable */, 4 /* Port Unreachable */, 0, 0, 0, 0, 0, 0}, quote...)
+	binary.BigEndian.PutUint16(icmpMsg[2:], checksum(icmpMsg, icmpv6PseudoSum(target.Addr(), client.Addr(), len(icmpMsg))))
+	return append(ipv6Header(target.Addr(), client.Addr(), unix.IPPROTO_ICMPV6, len(icmpMsg)), icmpMsg...), nil
+}
+
+// icmpv6PseudoSum is the sum of the IPv6 pseudo header, which is covered by the ICMPv6 checksum.
+func icmpv6PseudoSum(src, dst netip.Addr, length int) uint32 {
+	s, d := src.As16(), dst.As16()
+	return sum(d[:], sum(s[:], 0)) + uint32(length) + unix.IPPROTO_ICMPV6
+}
+
+func ipv4Header(src, dst netip.Addr, proto int, payloadLen int) []byte {
+	h := make([]byte, 20)
+	h[0] = 0x45
+	binary.BigEndian.PutUint16(h[2:], uint16(20+payloadLen))
+	h[8] = 64
+	h[9] = byte(proto)
+	copy(h[12:16], src.AsSlice())
+	copy(h[16:20], dst.AsSlice())
+	binary.BigEndian.PutUint16(h[10:], checksum(h, 0))
+	return h
+}
+
+func ipv6Header(src, dst netip.Addr, proto int, payloadLen int) []byte {
+	h := make([]byte, 40)
+	h[0] = 0x60
+	binary.BigEndian.PutUint16(h[4:], uint16(payloadLen))
+	h[6] = byte(proto)
+	h[7] = 64
+	copy(h[8:24], src.AsSlice())
+	copy(h[24:40], dst.AsSlice())
+	return h
+}
+
+func sum(b []byte, s uint32) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		s += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		s += uint32(b[len(b)-1]) << 8
+	}
+	return s
+}
+
+// checksum is the internet checksum of b with the initial sum s (RFC 1071).
+func checksum(b []byte, s uint32) uint16 {
+	s = sum(b, s)
+	for s>>16 != 0 {
+		s = (s & 0xffff) + (s >> 16)
+	}
+	return ^uint16(s)
+}
@@ -0,0 +1,87 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/daeuniverse/dae/common/consts"
+	dnsmessage "github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+func TestUdpUnreachablePacket(t *testing.T) {
+	payload := []byte("hello")
+
+	client, target := netip.MustParseAddrPort("[::ffff:192.168.1.2]:50000"), netip.MustParseAddrPort("1.1.1.1:443")
+	pkt, err := udpUnreachablePacket(client, target, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt[9] != unix.IPPROTO_ICMP || netip.AddrFrom4([4]byte(pkt[12:16])) != target.Addr() || netip.AddrFrom4([4]byte(pkt[16:20])) != client.Addr().Unmap() {
+		t.Fatalf("bad ipv4 header: %x", pkt[:20])
+	}
+	if checksum(pkt[:20], 0) != 0 || checksum(pkt[20:], 0) != 0 {
+		t.Errorf("bad ipv4 checksums: %x", pkt)
+	}
+	if pkt[20] != 3 || pkt[21] != 3 || len(pkt) != 20+8+20+8 {
+		t.Errorf("bad icmp message: %x", pkt[20:])
+	}
+
+	client, target = netip.MustParseAddrPort("[2001:db8::2]:50000"), netip.MustParseAddrPort("[2606:4700::1111]:443")
+	if pkt, err = udpUnreachablePacket(client, target, payload); err != nil {
+		t.Fatal(err)
+	}
+	if pkt[6] != unix.IPPROTO_ICMPV6 || pkt[40] != 1 || pkt[41] != 4 || len(pkt) != 40+8+40+8+len(payload) {
+		t.Fatalf("bad icmpv6 packet: %x", pkt)
+	}
+	if checksum(pkt[40:], icmpv6PseudoSum(target.Addr(), client.Addr(), len(pkt)-40)) != 0 {
+		t.Errorf("bad icmpv6 checksum: %x", pkt[40:])
+	}
+
+	if _, err = udpUnreachablePacket(client, netip.MustParseAddrPort("1.1.1.1:443"), payload); err == nil {
+		t.Errorf("expected error for mismatched ip versions")
+	}
+}
+
+// newTestRoutingControlPlane creates a control plane routing all traffic to outbound in userspace.
+func newTestRoutingControlPlane(outbound consts.OutboundIndex) *ControlPlane {
+	return &ControlPlane{
+		log: logrus.New(),
+		routingMatcher: &RoutingMatcher{
+			matches: []bpfMatchSet{{Type: uint8(consts.MatchType_Fallback), Outbound: uint8(outbound)}},
+		},
+	}
+}
+
+func newTestDnsQuery(t *testing.T) []byte {
+	msg := new(dnsmessage.Msg)
+	msg.SetQuestion("telemetry.example.com.", dnsmessage.TypeA)
+	b, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHandlePkt_RejectDns(t *testing.T) {
+	src, dst := netip.MustParseAddrPort("127.0.0.1:50000"), netip.MustParseAddrPort("127.0.0.2:53")
+	// The DNS controller is nil, and handlePkt panics if the query is answered by dae.
+	c := newTestRoutingControlPlane(consts.OutboundDrop)
+	routingResult := &bpfRoutingResult{Outbound: uint8(consts.OutboundControlPlaneRouting)}
+	if err := c.handlePkt(nil, newTestDnsQuery(t), src, dst, dst, routingResult, false); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newTestRoutingControlPlane(consts.OutboundRejectIcmp)
+	routingResult = &bpfRoutingResult{Outbound: uint8(consts.OutboundControlPlaneRouting)}
+	if err := c.handlePkt(nil, newTestDnsQuery(t), src, dst, dst, routingResult, false); err != nil {
+		// Raw sockets need CAP_NET_RAW.
+		t.Logf("send icmp unreachable: %v", err)
+	}
+}
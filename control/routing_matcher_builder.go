@@ -95,11 +95,12 @@ func (b *RoutingMatcherBuilder) addDomain(f *config_parser.Function, key string,
 		return err
 	}
 	b.rules = append(b.rules, bpfMatchSet{
-		Type:     uint8(consts.MatchType_DomainSet),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Type:       uint8(consts.MatchType_DomainSet),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	})
 	return nil
 }
@@ -119,12 +120,13 @@ func (b *RoutingMatcherBuilder) addSourceMac(f *config_parser.Function, macAddrs
 		return err
 	}
 	set := bpfMatchSet{
-		Value:    [16]byte{},
-		Type:     uint8(consts.MatchType_Mac),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Value:      [16]byte{},
+		Type:       uint8(consts.MatchType_Mac),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	}
 	binary.LittleEndian.PutUint32(set.Value[:], uint32(lpmTrieIndex))
 	b.rules = append(b.rules, set)
@@ -139,12 +141,13 @@ func (b *RoutingMatcherBuilder) addIp(f *config_parser.Function, values []netip.
 		return err
 	}
 	set := bpfMatchSet{
-		Value:    [16]byte{},
-		Type:     uint8(consts.MatchType_IpSet),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Value:      [16]byte{},
+		Type:       uint8(consts.MatchType_IpSet),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	}
 	binary.LittleEndian.PutUint32(set.Value[:], uint32(lpmTrieIndex))
 	b.rules = append(b.rules, set)
@@ -167,10 +170,11 @@ func (b *RoutingMatcherBuilder) addPort(f *config_parser.Function, values [][2]u
 				PortStart: value[0],
 				PortEnd:   value[1],
 			}.Encode(),
-			Not:      f.Not,
-			Outbound: outboundId,
-			Mark:     outbound.Mark,
-			Must:     outbound.Must,
+			Not:        f.Not,
+			Outbound:   outboundId,
+			Mark:       outbound.Mark,
+			Must:       outbound.Must,
+			UdpNatMode: uint8(outbound.UdpNatMode),
 		})
 	}
 	return nil
@@ -184,12 +188,13 @@ func (b *RoutingMatcherBuilder) addSourceIp(f *config_parser.Function, values []
 		return err
 	}
 	set := bpfMatchSet{
-		Value:    [16]byte{},
-		Type:     uint8(consts.MatchType_SourceIpSet),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Value:      [16]byte{},
+		Type:       uint8(consts.MatchType_SourceIpSet),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	}
 	binary.LittleEndian.PutUint32(set.Value[:], uint32(lpmTrieIndex))
 	b.rules = append(b.rules, set)
@@ -212,10 +217,11 @@ func (b *RoutingMatcherBuilder) addSourcePort(f *config_parser.Function, values
 				PortStart: value[0],
 				PortEnd:   value[1],
 			}.Encode(),
-			Not:      f.Not,
-			Outbound: outboundId,
-			Mark:     outbound.Mark,
-			Must:     outbound.Must,
+			Not:        f.Not,
+			Outbound:   outboundId,
+			Mark:       outbound.Mark,
+			Must:       outbound.Must,
+			UdpNatMode: uint8(outbound.UdpNatMode),
 		})
 	}
 	return nil
@@ -227,12 +233,13 @@ func (b *RoutingMatcherBuilder) addL4Proto(f *config_parser.Function, values con
 		return err
 	}
 	b.rules = append(b.rules, bpfMatchSet{
-		Value:    [16]byte{byte(values)},
-		Type:     uint8(consts.MatchType_L4Proto),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Value:      [16]byte{byte(values)},
+		Type:       uint8(consts.MatchType_L4Proto),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	})
 	return nil
 }
@@ -243,12 +250,13 @@ func (b *RoutingMatcherBuilder) addIpVersion(f *config_parser.Function, values c
 		return err
 	}
 	b.rules = append(b.rules, bpfMatchSet{
-		Value:    [16]byte{byte(values)},
-		Type:     uint8(consts.MatchType_IpVersion),
-		Not:      f.Not,
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Value:      [16]byte{byte(values)},
+		Type:       uint8(consts.MatchType_IpVersion),
+		Not:        f.Not,
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	})
 	return nil
 }
@@ -264,11 +272,12 @@ func (b *RoutingMatcherBuilder) addProcessName(f *config_parser.Function, values
 			return err
 		}
 		matchSet := bpfMatchSet{
-			Type:     uint8(consts.MatchType_ProcessName),
-			Not:      f.Not,
-			Outbound: outboundId,
-			Mark:     outbound.Mark,
-			Must:     outbound.Must,
+			Type:       uint8(consts.MatchType_ProcessName),
+			Not:        f.Not,
+			Outbound:   outboundId,
+			Mark:       outbound.Mark,
+			Must:       outbound.Must,
+			UdpNatMode: uint8(outbound.UdpNatMode),
 		}
 		copy(matchSet.Value[:], value[:])
 		b.rules = append(b.rules, matchSet)
@@ -287,11 +296,12 @@ func (b *RoutingMatcherBuilder) addDscp(f *config_parser.Function, values []uint
 			return err
 		}
 		matchSet := bpfMatchSet{
-			Type:     uint8(consts.MatchType_Dscp),
-			Not:      f.Not,
-			Outbound: outboundId,
-			Mark:     outbound.Mark,
-			Must:     outbound.Must,
+			Type:       uint8(consts.MatchType_Dscp),
+			Not:        f.Not,
+			Outbound:   outboundId,
+			Mark:       outbound.Mark,
+			Must:       outbound.Must,
+			UdpNatMode: uint8(outbound.UdpNatMode),
 		}
 		matchSet.Value[0] = value
 		b.rules = append(b.rules, matchSet)
@@ -309,10 +319,11 @@ func (b *RoutingMatcherBuilder) addFallback(fallbackOutbound config.FunctionOrSt
 		return err
 	}
 	b.rules = append(b.rules, bpfMatchSet{
-		Type:     uint8(consts.MatchType_Fallback),
-		Outbound: outboundId,
-		Mark:     outbound.Mark,
-		Must:     outbound.Must,
+		Type:       uint8(consts.MatchType_Fallback),
+		Outbound:   outboundId,
+		Mark:       outbound.Mark,
+		Must:       outbound.Must,
+		UdpNatMode: uint8(outbound.UdpNatMode),
 	})
 	return nil
 }
@@ -0,0 +1,129 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// SelectionFileName is the name of file in the config directory that persists nodes selected for groups with
+// policy select.
+const SelectionFileName = "selection.json"
+
+// Selection maps group name to the selected node name.
+type Selection map[string]string
+
+// ReadSelection reads the selection file. It returns an empty selection if the file does not exist.
+func ReadSelection(path string) (Selection, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Selection{}, nil
+		}
+		return nil, err
+	}
+	selection := Selection{}
+	if len(b) == 0 {
+		return selection, nil
+	}
+	if err = json.Unmarshal(b, &selection); err != nil {
+		return nil, fmt.Errorf("bad selection file %v: %w", path, err)
+	}
+	return selection, nil
+}
+
+// WriteSelection writes the selection file atomically.
+func WriteSelection(path string, selection Selection) error {
+	b, err := json.MarshalIndent(selection, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ApplySelection applies the selection to groups with policy select. Groups with policy select but absent in the
+// selection fall back to their fallback policies.
+func (c *ControlPlane) ApplySelection(selection Selection) {
+	for _, g := range c.outbounds[consts.OutboundUserDefinedMin:] {
+		if g.GetSelectionPolicy() != consts.DialerSelectionPolicy_Select {
+			if _, ok := selection[g.Name]; ok {
+				c.log.WithField("group", g.Name).Warnf("Ignore selection of group with policy %v", g.GetSelectionPolicy())
+			}
+			continue
+		}
+		name := selection[g.Name]
+		if err := g.SetSelected(name); err != nil {
+			c.log.WithField("group", g.Name).Warnf("Ignore selection: %v", err)
+			continue
+		}
+		if name != "" {
+			c.log.WithFields(logrus.Fields{
+				"group": g.Name,
+				"node":  name,
+			}).Infoln("Group selects node manually")
+		}
+	}
+}
+
+// WatchSelection applies the selection file and watches it to apply changes until the control plane closes.
+func (c *ControlPlane) WatchSelection(path string) error {
+	selection, err := ReadSelection(path)
+	if err != nil {
+		return err
+	}
+	c.ApplySelection(selection)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory because the file is replaced by rename.
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) ||
+					!event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) {
+					continue
+				}
+				selection, err := ReadSelection(path)
+				if err != nil {
+					c.log.Warnln(err)
+					continue
+				}
+				c.ApplySelection(selection)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				c.log.Errorf("selection watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -7,6 +7,7 @@ package control
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/daeuniverse/dae/common"
 	"github.com/daeuniverse/dae/common/consts"
+	ob "github.com/daeuniverse/dae/component/outbound"
 	"github.com/daeuniverse/dae/component/outbound/dialer"
 	"github.com/daeuniverse/dae/component/sniffing"
 	"github.com/daeuniverse/outbound/netproxy"
@@ -46,7 +48,7 @@ func (c *ControlPlane) handleConn(lConn net.Conn) (err error) {
 	dst = common.ConvergeAddrPort(dst)
 
 	// Dial and relay.
-	rConn, err := c.RouteDialTcp(&RouteDialParam{
+	rConn, release, err := c.routeDialTcp(&RouteDialParam{
 		Outbound:    consts.OutboundIndex(routingResult.Outbound),
 		Domain:      domain,
 		Mac:         routingResult.Mac,
@@ -57,11 +59,24 @@ func (c *ControlPlane) handleConn(lConn net.Conn) (err error) {
 		Mark:        routingResult.Mark,
 	})
 	if err != nil {
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			if rejectErr.Outbound != consts.OutboundDrop {
+				resetTcp(lConn)
+			}
+			return nil
+		}
 		return fmt.Errorf("failed to dial %v: %w", dst, err)
 	}
 	defer rConn.Close()
+	defer release()
+
+	return relayConn(sniffer, rConn)
+}
 
-	if err = RelayTCP(sniffer, rConn); err != nil {
+// relayConn relays between the client and the remote, and ignores errors of closed connections.
+func relayConn(lConn, rConn netproxy.Conn) error {
+	if err := RelayTCP(lConn, rConn); err != nil {
 		switch {
 		case strings.HasSuffix(err.Error(), "write: broken pipe"),
 			strings.HasSuffix(err.Error(), "i/o timeout"),
@@ -88,7 +103,19 @@ type RouteDialParam struct {
 	Mark        uint32
 }
 
+// RouteDialTcp routes and dials. The connection is not counted in flight by the balance policy.
 func (c *ControlPlane) RouteDialTcp(p *RouteDialParam) (conn netproxy.Conn, err error) {
+	conn, release, err := c.routeDialTcp(p)
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return conn, nil
+}
+
+// routeDialTcp is like RouteDialTcp, but counts the connection in flight through the selected dialer from the start of
+// dialing until release is called.
+func (c *ControlPlane) routeDialTcp(p *RouteDialParam) (conn netproxy.Conn, release func(), err error) {
 	routingResult := &bpfRoutingResult{
 		Mark:     p.Mark,
 		Must:     0,
@@ -112,7 +139,7 @@ func (c *ControlPlane) RouteDialTcp(p *RouteDialParam) (conn netproxy.Conn, err
 	case consts.OutboundDirect:
 	case consts.OutboundControlPlaneRouting:
 		if outboundIndex, routingResult.Mark, _, err = c.Route(src, dst, domain, consts.L4ProtoType_TCP, routingResult); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		routingResult.Outbound = uint8(outboundIndex)
 
@@ -129,23 +156,41 @@ func (c *ControlPlane) RouteDialTcp(p *RouteDialParam) (conn netproxy.Conn, err
 	if routingResult.Mark == 0 {
 		routingResult.Mark = c.soMarkFromDae
 	}
+	networkType := &dialer.NetworkType{
+		L4Proto:   consts.L4ProtoStr_TCP,
+		IpVersion: consts.IpVersionFromAddr(dst.Addr()),
+		IsDns:     false,
+	}
+	if outboundIndex.IsRejection() {
+		if c.log.IsLevelEnabled(logrus.InfoLevel) {
+			c.log.WithFields(logrus.Fields{
+				"network":  networkType.String(),
+				"outbound": outboundIndex.String(),
+				"sniffed":  domain,
+				"ip":       RefineAddrPortToShow(dst),
+				"pname":    ProcessName2String(routingResult.Pname[:]),
+				"mac":      Mac2String(routingResult.Mac[:]),
+			}).Infof("%v <-> %v", RefineSourceToShow(src, dst.Addr()), dialTarget)
+		}
+		return nil, nil, &RejectError{Outbound: outboundIndex}
+	}
 	// TODO: Set-up ip to domain mapping and show domain if possible.
 	if int(outboundIndex) >= len(c.outbounds) {
 		if len(c.outbounds) == int(consts.OutboundUserDefinedMin) {
-			return nil, fmt.Errorf("traffic was dropped due to no-load configuration")
+			return nil, nil, fmt.Errorf("traffic was dropped due to no-load configuration")
 		}
-		return nil, fmt.Errorf("outbound id from bpf is out of range: %v not in [0, %v]", outboundIndex, len(c.outbounds)-1)
+		return nil, nil, fmt.Errorf("outbound id from bpf is out of range: %v not in [0, %v]", outboundIndex, len(c.outbounds)-1)
 	}
 	outbound := c.outbounds[outboundIndex]
-	networkType := &dialer.NetworkType{
-		L4Proto:   consts.L4ProtoStr_TCP,
-		IpVersion: consts.IpVersionFromAddr(dst.Addr()),
-		IsDns:     false,
-	}
 	strictIpVersion := dialIp
-	d, _, err := outbound.Select(networkType, strictIpVersion)
+	d, _, err := outbound.SelectFor(networkType, strictIpVersion, &ob.SelectOption{
+		Src:    src.Addr(),
+		Mac:    routingResult.Mac,
+		Dst:    dst.Addr(),
+		Domain: domain,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to select dialer from group %v (%v): %w", outbound.Name, networkType.String(), err)
+		return nil, nil, fmt.Errorf("failed to select dialer from group %v (%v): %w", outbound.Name, networkType.String(), err)
 	}
 
 	if c.log.IsLevelEnabled(logrus.InfoLevel) {
@@ -162,9 +207,95 @@ func (c *ControlPlane) RouteDialTcp(p *RouteDialParam) (conn netproxy.Conn, err
 			"mac":      Mac2String(routingResult.Mac[:]),
 		}).Infof("%v <-> %v", RefineSourceToShow(src, dst.Addr()), dialTarget)
 	}
+	// All attempts share the timeout, which is the connect budget of the client.
 	ctx, cancel := context.WithTimeout(context.TODO(), consts.DefaultDialTimeout)
 	defer cancel()
-	return d.DialContext(ctx, common.MagicNetwork("tcp", routingResult.Mark, c.mptcp), dialTarget)
+	conn, release, err = c.dialTcpWithRetry(ctx, outbound, d, networkType, routingResult.Mark, dialTarget)
+	if err != nil && outbound.OnFail != nil && ctx.Err() == nil {
+		fallback := outbound.OnFail
+		fallbackDialer, _, e := fallback.SelectFor(networkType, strictIpVersion, &ob.SelectOption{
+			Src:    src.Addr(),
+			Mac:    routingResult.Mac,
+			Dst:    dst.Addr(),
+			Domain: domain,
+		})
+		if e != nil {
+			return nil, nil, fmt.Errorf("%w; failed to select dialer from on_fail group %v: %v", err, fallback.Name, e)
+		}
+		if c.log.IsLevelEnabled(logrus.InfoLevel) {
+			c.log.WithFields(logrus.Fields{
+				"network":  networkType.String(),
+				"outbound": fallback.Name,
+				"dialer":   fallbackDialer.Property().Name,
+				"error":    err,
+			}).Infof("Group %v fails to dial %v; fall back to on_fail group", outbound.Name, dialTarget)
+		}
+		conn, release, err = c.dialTcpWithRetry(ctx, fallback, fallbackDialer, networkType, routingResult.Mark, dialTarget)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if redirect, ok := c.redirects[outboundIndex]; ok && redirect.ProxyProtocol {
+		// The original destination is lost after redirecting, so it is told to the target by the header.
+		if _, err = conn.Write(proxyProtocolV2Header(src, dst)); err != nil {
+			_ = conn.Close()
+			release()
+			return nil, nil, fmt.Errorf("failed to write proxy protocol header: %w", err)
+		}
+	}
+	return conn, release, nil
+}
+
+// maxDialRetries bounds how many alternative dialers of a group are dialed after the selected one fails.
+const maxDialRetries = 2
+
+// dialTcpWithRetry dials through d, and retries with the next best alive dialers of the group if dialing fails. The
+// connection is counted in flight through the dialer from the start of dialing until release is called. It is
+// only used before anything is relayed, so retrying is invisible to the client.
+func (c *ControlPlane) dialTcpWithRetry(ctx context.Context, g *ob.DialerGroup, d *dialer.Dialer, networkType *dialer.NetworkType, mark uint32, dialTarget string) (conn netproxy.Conn, release func(), err error) {
+	var tried []*dialer.Dialer
+	for {
+		// Count it before dialing, so that the balance policy sees connections still dialing.
+		release = d.AcquireInFlight()
+		// Mptcp can be overridden by the group.
+		conn, err = d.DialContext(ctx, common.MagicNetwork("tcp", mark, d.Mptcp), dialTarget)
+		if err == nil {
+			if observePassiveHealth(g) {
+				d.ReportDialSuccess(networkType)
+			}
+			return conn, release, nil
+		}
+		release()
+		if !observePassiveHealth(g) {
+			// Failures of groups with policy fixed are usually caused by the target.
+			return nil, nil, err
+		}
+		d.ReportDialFailure(networkType, dialTarget, err)
+		tried = append(tried, d)
+		if len(tried) > maxDialRetries || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		next := g.SelectAlternative(networkType, tried)
+		if next == nil {
+			return nil, nil, err
+		}
+		if c.log.IsLevelEnabled(logrus.InfoLevel) {
+			c.log.WithFields(logrus.Fields{
+				"network":  networkType.String(),
+				"outbound": g.Name,
+				"dialer":   next.Property().Name,
+				"error":    err,
+			}).Infof("Dialer %v fails to dial %v; retry with another dialer", d.Property().Name, dialTarget)
+		}
+		d = next
+	}
+}
+
+// observePassiveHealth reports whether real traffic through the group should feed the health of dialers. Groups with
+// policy fixed, such as direct and block, do not care about the alive state, and failures of them are usually caused by
+// the target.
+func observePassiveHealth(g *ob.DialerGroup) bool {
+	return g.GetSelectionPolicy() != consts.DialerSelectionPolicy_Fixed
 }
 
 type WriteCloser interface {
@@ -6,6 +6,7 @@
 package control
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
@@ -36,6 +37,7 @@ type DialOption struct {
 	Target        string
 	Dialer        *dialer.Dialer
 	Outbound      *ob.DialerGroup
+	NetworkType   *dialer.NetworkType
 	Network       string
 	SniffedDomain string
 }
@@ -61,11 +63,44 @@ func sendPkt(log *logrus.Logger, data []byte, from netip.AddrPort, realTo, to ne
 	return err
 }
 
+// rejectUdp drops the UDP packet for outbound drop, or replies ICMP port unreachable for outbound reject.
+func (c *ControlPlane) rejectUdp(outbound consts.OutboundIndex, realSrc, realDst netip.AddrPort, domain string, routingResult *bpfRoutingResult, data []byte) error {
+	if c.log.IsLevelEnabled(logrus.InfoLevel) {
+		c.log.WithFields(logrus.Fields{
+			"network":  string(consts.L4ProtoStr_UDP) + string(consts.IpVersionFromAddr(realDst.Addr())),
+			"outbound": outbound.String(),
+			"sniffed":  domain,
+			"ip":       RefineAddrPortToShow(realDst),
+			"pname":    ProcessName2String(routingResult.Pname[:]),
+			"mac":      Mac2String(routingResult.Mac[:]),
+		}).Infof("%v <-> %v", RefineSourceToShow(realSrc, realDst.Addr()), realDst)
+	}
+	if outbound == consts.OutboundDrop {
+		return nil
+	}
+	// There is no TCP reset for UDP, and both reject outbounds send ICMP port unreachable.
+	return sendUdpUnreachable(realSrc, realDst, data)
+}
+
+// chooseUdpNatMode returns the udp_nat_mode of the rule hit by the packet, or the global one if the rule does not
+// override it.
+func (c *ControlPlane) chooseUdpNatMode(routingResult *bpfRoutingResult) consts.UdpNatMode {
+	if mode := consts.UdpNatMode(routingResult.UdpNatMode); mode != consts.UdpNatMode_Default {
+		return mode
+	}
+	return c.udpNatMode
+}
+
 func (c *ControlPlane) handlePkt(lConn *net.UDPConn, data []byte, src, pktDst, realDst netip.AddrPort, routingResult *bpfRoutingResult, skipSniffing bool) (err error) {
 	var realSrc netip.AddrPort
 	var domain string
 	realSrc = src
-	ue, ueExists := DefaultUdpEndpointPool.Get(realSrc)
+	// Endpoints are shared by all destinations of the source in full-cone mode.
+	ueKey := UdpEndpointKey{Src: realSrc}
+	if c.chooseUdpNatMode(routingResult) == consts.UdpNatMode_PerDestination {
+		ueKey.Dst = realDst
+	}
+	ue, ueExists := DefaultUdpEndpointPool.Get(ueKey)
 	if ueExists && ue.SniffedDomain != "" {
 		// It is quic ...
 		// Fast path.
@@ -149,6 +184,23 @@ func (c *ControlPlane) handlePkt(lConn *net.UDPConn, data []byte, src, pktDst, r
 	if routingResult.Must > 0 {
 		isDns = false // Regard as plain traffic.
 	}
+	if isDns && consts.OutboundIndex(routingResult.Outbound) == consts.OutboundControlPlaneRouting {
+		// The kernel sends DNS without "must" to the control plane and hides the outbound of the rule, which should
+		// not be answered by dae if it is drop, reject or redirect.
+		outboundIndex, mark, _, err := c.Route(realSrc, realDst, "", consts.L4ProtoType_UDP, routingResult)
+		if err != nil {
+			return err
+		}
+		if outboundIndex.IsRejection() {
+			return c.rejectUdp(outboundIndex, realSrc, realDst, "", routingResult, data)
+		}
+		if _, ok := c.redirects[outboundIndex]; ok {
+			// Send the query to the redirect target as plain traffic.
+			isDns = false
+			routingResult.Outbound = uint8(outboundIndex)
+			routingResult.Mark = mark
+		}
+	}
 	if routingResult.Mark == 0 {
 		routingResult.Mark = c.soMarkFromDae
 	}
@@ -166,6 +218,7 @@ func (c *ControlPlane) handlePkt(lConn *net.UDPConn, data []byte, src, pktDst, r
 	// TODO: Rewritten domain should not use full-cone (such as VMess Packet Addr).
 	// 		Maybe we should set up a mapping for UDP: Dialer + Target Domain => Remote Resolved IP.
 	//		However, games may not use QUIC for communication, thus we cannot use domain to dial, which is fine.
+	//		Flows with udp_nat_mode per_destination do not share endpoints with other destinations.
 
 	// Get udp endpoint.
 	retry := 0
@@ -198,7 +251,7 @@ getNew:
 		}).Warnln("Touch max retry limit.")
 		return fmt.Errorf("touch max retry limit")
 	}
-	ue, isNew, err := DefaultUdpEndpointPool.GetOrCreate(realSrc, &UdpEndpointOptions{
+	ue, isNew, err := DefaultUdpEndpointPool.GetOrCreate(ueKey, &UdpEndpointOptions{
 		// Handler handles response packets and send it to the client.
 		Handler: func(data []byte, from netip.AddrPort) (err error) {
 			// Do not return conn-unrelated err in this func.
@@ -235,6 +288,9 @@ getNew:
 			default:
 			}
 
+			if outboundIndex.IsRejection() {
+				return nil, &RejectError{Outbound: outboundIndex}
+			}
 			if int(outboundIndex) >= len(c.outbounds) {
 				if len(c.outbounds) == int(consts.OutboundUserDefinedMin) {
 					return nil, fmt.Errorf("traffic was dropped due to no-load configuration")
@@ -245,7 +301,12 @@ getNew:
 
 			// Select dialer from outbound (dialer group).
 			strictIpVersion := dialIp
-			dialerForNew, _, err := outbound.Select(networkType, strictIpVersion)
+			dialerForNew, _, err := outbound.SelectFor(networkType, strictIpVersion, &ob.SelectOption{
+				Src:    realSrc.Addr(),
+				Mac:    routingResult.Mac,
+				Dst:    realDst.Addr(),
+				Domain: domain,
+			})
 			if err != nil {
 				return nil, fmt.Errorf("failed to select dialer from group %v (%v, dns?:%v,from: %v): %w", outbound.Name, networkType.StringWithoutDns(), isDns, realSrc.String(), err)
 			}
@@ -253,12 +314,17 @@ getNew:
 				Target:        dialTarget,
 				Dialer:        dialerForNew,
 				Outbound:      outbound,
+				NetworkType:   networkType,
 				Network:       common.MagicNetwork("udp", routingResult.Mark, c.mptcp),
 				SniffedDomain: domain,
 			}, nil
 		},
 	})
 	if err != nil {
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			return c.rejectUdp(rejectErr.Outbound, realSrc, realDst, domain, routingResult, data)
+		}
 		return fmt.Errorf("failed to GetOrCreate: %w", err)
 	}
 
@@ -273,7 +339,7 @@ getNew:
 				"retry":   retry,
 			}).Debugln("Old udp endpoint was not alive and removed.")
 		}
-		_ = DefaultUdpEndpointPool.Remove(realSrc, ue)
+		_ = DefaultUdpEndpointPool.Remove(ueKey, ue)
 		retry++
 		goto getNew
 	}
@@ -284,6 +350,9 @@ getNew:
 
 	_, err = ue.WriteTo(data, dialTarget)
 	if err != nil {
+		if observePassiveHealth(ue.Outbound) {
+			ue.Dialer.ReportDialFailure(networkType, dialTarget, err)
+		}
 		if c.log.IsLevelEnabled(logrus.DebugLevel) {
 			c.log.WithFields(logrus.Fields{
 				"to":      realDst.String(),
@@ -298,7 +367,7 @@ getNew:
 				"retry":   retry,
 			}).Debugln("Failed to write UDP packet request. Try to remove old UDP endpoint and retry.")
 		}
-		_ = DefaultUdpEndpointPool.Remove(realSrc, ue)
+		_ = DefaultUdpEndpointPool.Remove(ueKey, ue)
 		retry++
 		goto getNew
 	}
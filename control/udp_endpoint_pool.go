@@ -31,6 +31,10 @@ type UdpEndpoint struct {
 
 	Dialer   *dialer.Dialer
 	Outbound *outbound.DialerGroup
+	// networkType is non-nil if received packets should feed the health of Dialer.
+	networkType *dialer.NetworkType
+
+	releaseInFlight func()
 
 	// Non-empty indicates this UDP Endpoint is related with a sniffed domain.
 	SniffedDomain string
@@ -45,6 +49,11 @@ func (ue *UdpEndpoint) start() {
 		if err != nil {
 			break
 		}
+		if ue.networkType != nil {
+			// Only the first response is reported.
+			ue.Dialer.ReportDialSuccess(ue.networkType)
+			ue.networkType = nil
+		}
 		ue.mu.Lock()
 		ue.deadlineTimer.Reset(ue.NatTimeout)
 		ue.mu.Unlock()
@@ -67,10 +76,18 @@ func (ue *UdpEndpoint) Close() error {
 		ue.deadlineTimer.Stop()
 	}
 	ue.mu.Unlock()
+	ue.releaseInFlight()
 	return ue.conn.Close()
 }
 
-// UdpEndpointPool is a full-cone udp conn pool
+// UdpEndpointKey identifies a UdpEndpoint in the pool. Dst is invalid for full-cone endpoints, which are shared by all
+// destinations of Src.
+type UdpEndpointKey struct {
+	Src netip.AddrPort
+	Dst netip.AddrPort
+}
+
+// UdpEndpointPool is a udp conn pool, which is full-cone or per-destination by keys.
 type UdpEndpointPool struct {
 	pool        sync.Map
 	createMuMap sync.Map
@@ -88,8 +105,8 @@ func NewUdpEndpointPool() *UdpEndpointPool {
 	return &UdpEndpointPool{}
 }
 
-func (p *UdpEndpointPool) Remove(lAddr netip.AddrPort, udpEndpoint *UdpEndpoint) (err error) {
-	if ue, ok := p.pool.LoadAndDelete(lAddr); ok {
+func (p *UdpEndpointPool) Remove(key UdpEndpointKey, udpEndpoint *UdpEndpoint) (err error) {
+	if ue, ok := p.pool.LoadAndDelete(key); ok {
 		if ue != udpEndpoint {
 			udpEndpoint.Close()
 			return fmt.Errorf("target udp endpoint is not in the pool")
@@ -99,23 +116,23 @@ func (p *UdpEndpointPool) Remove(lAddr netip.AddrPort, udpEndpoint *UdpEndpoint)
 	return nil
 }
 
-func (p *UdpEndpointPool) Get(lAddr netip.AddrPort) (udpEndpoint *UdpEndpoint, ok bool) {
-	_ue, ok := p.pool.Load(lAddr)
+func (p *UdpEndpointPool) Get(key UdpEndpointKey) (udpEndpoint *UdpEndpoint, ok bool) {
+	_ue, ok := p.pool.Load(key)
 	if !ok {
 		return nil, ok
 	}
 	return _ue.(*UdpEndpoint), ok
 }
 
-func (p *UdpEndpointPool) GetOrCreate(lAddr netip.AddrPort, createOption *UdpEndpointOptions) (udpEndpoint *UdpEndpoint, isNew bool, err error) {
-	_ue, ok := p.pool.Load(lAddr)
+func (p *UdpEndpointPool) GetOrCreate(key UdpEndpointKey, createOption *UdpEndpointOptions) (udpEndpoint *UdpEndpoint, isNew bool, err error) {
+	_ue, ok := p.pool.Load(key)
 begin:
 	if !ok {
-		createMu, _ := p.createMuMap.LoadOrStore(lAddr, &sync.Mutex{})
+		createMu, _ := p.createMuMap.LoadOrStore(key, &sync.Mutex{})
 		createMu.(*sync.Mutex).Lock()
 		defer createMu.(*sync.Mutex).Unlock()
-		defer p.createMuMap.Delete(lAddr)
-		_ue, ok = p.pool.Load(lAddr)
+		defer p.createMuMap.Delete(key)
+		_ue, ok = p.pool.Load(key)
 		if ok {
 			goto begin
 		}
@@ -136,11 +153,19 @@ begin:
 		}
 		ctx, cancel := context.WithTimeout(context.TODO(), consts.DefaultDialTimeout)
 		defer cancel()
+		// Count it before dialing, so that the balance policy sees endpoints still dialing.
+		releaseInFlight := dialOption.Dialer.AcquireInFlight()
 		udpConn, err := dialOption.Dialer.DialContext(ctx, dialOption.Network, dialOption.Target)
 		if err != nil {
+			releaseInFlight()
+			if dialOption.NetworkType != nil && observePassiveHealth(dialOption.Outbound) {
+				dialOption.Dialer.ReportDialFailure(dialOption.NetworkType, dialOption.Target, err)
+			}
 			return nil, true, err
 		}
 		if _, ok = udpConn.(netproxy.PacketConn); !ok {
+			releaseInFlight()
+			_ = udpConn.Close()
 			return nil, true, fmt.Errorf("protocol does not support udp")
 		}
 		ue := &UdpEndpoint{
@@ -152,9 +177,14 @@ begin:
 			Outbound:      dialOption.Outbound,
 			SniffedDomain: dialOption.SniffedDomain,
 			DialTarget:    dialOption.Target,
+
+			releaseInFlight: releaseInFlight,
+		}
+		if dialOption.NetworkType != nil && observePassiveHealth(dialOption.Outbound) {
+			ue.networkType = dialOption.NetworkType
 		}
 		ue.deadlineTimer = time.AfterFunc(createOption.NatTimeout, func() {
-			if _ue, ok := p.pool.LoadAndDelete(lAddr); ok {
+			if _ue, ok := p.pool.LoadAndDelete(key); ok {
 				if _ue == ue {
 					ue.Close()
 				} else {
@@ -163,7 +193,7 @@ begin:
 			}
 		})
 		_ue = ue
-		p.pool.Store(lAddr, ue)
+		p.pool.Store(key, ue)
 		// Receive UDP messages.
 		go ue.start()
 		isNew = true
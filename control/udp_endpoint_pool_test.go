@@ -0,0 +1,57 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2022-2025, daeuniverse Organization <dae@v2raya.org>
+ */
+
+package control
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	"github.com/daeuniverse/outbound/protocol/direct"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUdpEndpointPool_Keys(t *testing.T) {
+	direct.InitDirectDialers("127.0.0.1:53")
+	option := &dialer.GlobalOption{Log: logrus.New()}
+	_d, p := dialer.NewDirectDialer(option, true)
+	d := dialer.NewDialer(_d, option, dialer.InstanceOption{DisableCheck: true}, p)
+	defer d.Close()
+	createOption := func(dst netip.AddrPort) *UdpEndpointOptions {
+		return &UdpEndpointOptions{
+			Handler: func(data []byte, from netip.AddrPort) error { return nil },
+			GetDialOption: func() (*DialOption, error) {
+				return &DialOption{Target: dst.String(), Dialer: d, Network: "udp"}, nil
+			},
+		}
+	}
+
+	pool := NewUdpEndpointPool()
+	src := netip.MustParseAddrPort("192.168.1.2:50000")
+	dst1, dst2 := netip.MustParseAddrPort("127.0.0.1:10001"), netip.MustParseAddrPort("127.0.0.1:10002")
+	fullCone, _, err := pool.GetOrCreate(UdpEndpointKey{Src: src}, createOption(dst1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Remove(UdpEndpointKey{Src: src}, fullCone)
+	if ue, isNew, _ := pool.GetOrCreate(UdpEndpointKey{Src: src}, createOption(dst2)); isNew || ue != fullCone {
+		t.Errorf("full-cone endpoint should be shared by destinations")
+	}
+
+	perDst1, _, err := pool.GetOrCreate(UdpEndpointKey{Src: src, Dst: dst1}, createOption(dst1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Remove(UdpEndpointKey{Src: src, Dst: dst1}, perDst1)
+	perDst2, isNew, err := pool.GetOrCreate(UdpEndpointKey{Src: src, Dst: dst2}, createOption(dst2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Remove(UdpEndpointKey{Src: src, Dst: dst2}, perDst2)
+	if !isNew || perDst1 == fullCone || perDst1 == perDst2 || perDst2.DialTarget != dst2.String() {
+		t.Errorf("per-destination endpoints should not be shared")
+	}
+}
@@ -6,6 +6,7 @@ toolchain go1.23.2
 
 require (
 	github.com/adrg/xdg v0.5.0
+	github.com/andybalholm/brotli v1.1.0
 	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230305170008-8188dc5388df
 	github.com/bits-and-blooms/bloom/v3 v3.7.0
 	github.com/cilium/ebpf v0.15.0
@@ -35,7 +36,6 @@ require (
 )
 
 require (
-	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/awnumar/fastrand v0.0.0-20210315215012-30ee0990fa2d // indirect
 	github.com/awnumar/memcall v0.3.0 // indirect
 	github.com/awnumar/memguard v0.22.5 // indirect
@@ -103,4 +103,4 @@ require (
 // replace github.com/daeuniverse/quic-go => ../quic-go
 
 //replace github.com/cilium/ebpf => /home/mzz/goProjects/ebpf
-//replace github.com/daeuniverse/dae-config-dist/go/dae_config => /home/mzz/antlrProjects/dae-config/build/go/dae_config
\ No newline at end of file
+//replace github.com/daeuniverse/dae-config-dist/go/dae_config => /home/mzz/antlrProjects/dae-config/build/go/dae_config